@@ -5,12 +5,18 @@
 package binding
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"mime/multipart"
+	"net/http"
+	"net/textproto"
 	"net/url"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
@@ -29,8 +35,151 @@ type Options struct {
 	// MaxMemory specifies the maximum amount of memory to be allowed when parsing a
 	// multipart form. Default is 10 MiB.
 	MaxMemory int64
+	// RepeatedKeys specifies how to bind a key that appears more than once in the
+	// form data into a non-slice field. Default is RepeatedKeysFirst.
+	RepeatedKeys RepeatedKeysPolicy
+	// MaxValuesPerKey specifies the maximum number of values to be allowed for a
+	// single key in the form data. Default is no limit.
+	MaxValuesPerKey int
+	// MaxKeys specifies the maximum number of distinct keys to be allowed in the
+	// form data. Default is no limit.
+	MaxKeys int
+	// Strict indicates whether to report keys in the payload that do not match any
+	// field of the model as errors. Default is to ignore them.
+	Strict bool
+	// ResetErrors indicates whether to discard binding.Errors mapped by previous
+	// binders in the handler chain. Default is to append to them.
+	ResetErrors bool
+	// BufferBody indicates whether to buffer the request body, so that it can be
+	// read again by subsequent binders and handlers. The buffered body is also
+	// injected as *binding.RawBody. Default is to read the request body directly.
+	BufferBody bool
+	// MaxBodyMemory specifies the maximum amount of memory to be allowed when
+	// buffering the request body, the rest is spilled to a temporary file. Default
+	// is 10 MiB.
+	MaxBodyMemory int64
+	// Decompressors contains additional decompressors for content codings of the
+	// request body other than "gzip" and "deflate", e.g. "br" and "zstd", keyed by
+	// the content coding in lower case.
+	Decompressors map[string]Decompressor
+	// MaxDecompressedSize specifies the maximum size in bytes of the request body
+	// after decompression. Default is 32 MiB.
+	MaxDecompressedSize int64
+	// MaxDecompressionRatio specifies the maximum ratio of the decompressed size
+	// to the compressed size of the request body, it is only checked after the
+	// first 1 MiB is decompressed. Default is 100.
+	MaxDecompressionRatio int
+	// RejectInvalidUTF8 indicates whether to report string fields that are invalid
+	// UTF-8 or contain NUL bytes as errors, after the payload is transcoded from
+	// the charset of the request. Default is to accept them.
+	RejectInvalidUTF8 bool
+	// MediaTypes specifies the allowed media types of the request body, each of
+	// them is an exact media type, a wildcard subtype like "text/*", or a
+	// structured syntax suffix like "+json". Requests without Content-Type are
+	// always allowed. Default is the media types natively supported by the binder.
+	MediaTypes []string
+	// MaxBodySize specifies the maximum size in bytes of the request body before
+	// decompression. It is checked against the Content-Length before reading the
+	// request body, and enforced while reading. Default is no limit.
+	MaxBodySize int64
+	// ReadTimeout specifies the maximum duration for reading the request body.
+	// The deadline is only set on the underlying connection by
+	// binding.Middleware when supported. Otherwise, including all flamego
+	// handlers like binding.JSON, each read is waited in a separate goroutine
+	// that is abandoned upon timeout, and the connection stays busy until the
+	// read returns. The same applies to MinReadRate. Default is no timeout.
+	ReadTimeout time.Duration
+	// MinReadRate specifies the minimum throughput in bytes per second for reading
+	// the request body, which is enforced after the first second. Default is no
+	// limit.
+	MinReadRate int64
+	// StreamMultipart indicates whether to stream the multipart form by walking
+	// its parts one by one, instead of staging the whole form in memory or
+	// temporary files before binding. File parts are handed to FileSinks, and
+	// fields of *multipart.FileHeader are not populated. Default is to stage the
+	// whole form.
+	StreamMultipart bool
+	// FileSinks contains the functions to consume file parts keyed by their form
+	// field names when StreamMultipart is set.
+	FileSinks map[string]FileSink
+	// Storage specifies the backend to save file parts of the multipart form into
+	// for fields of binding.StoredFile, which implies StreamMultipart. File parts
+	// with a sink in FileSinks are not saved. Saved files are removed after the
+	// handler chain finishes if there are any errors, or if the handler chain
+	// panics. Default is not to use any storage.
+	Storage Storage
+	// KeepMultipartFiles indicates whether to keep temporary files of the parsed
+	// multipart form after the handler chain finishes, and the handler becomes
+	// responsible for calling RemoveAll of http.Request.MultipartForm. Default is
+	// to remove them automatically.
+	KeepMultipartFiles bool
+	// MaxFileSize specifies the maximum size in bytes of each file in the
+	// multipart form, which can be overridden for a field by the "max" option of
+	// the "file" struct tag, e.g. `file:"max=5MB"`. Default is no limit.
+	MaxFileSize int64
+	// MaxUploadSize specifies the maximum total size in bytes of all files in the
+	// multipart form. Default is no limit.
+	MaxUploadSize int64
+	// MaxFiles specifies the maximum number of files in the multipart form, and
+	// the "count" option of the "file" struct tag limits the number of files for
+	// a field, e.g. `file:"count=3"`. Default is no limit.
+	MaxFiles int
+	// MaxParts specifies the maximum number of parts in the multipart form,
+	// including both values and files. Default is 1000 parts when the multipart
+	// form is streamed, and the limit of mime/multipart otherwise.
+	//
+	// These upload limits are enforced while reading the multipart form, which
+	// stops at the first part that exceeds them. A multipart form that has been
+	// parsed before binding is checked as a whole.
+	MaxParts int
+	// DetectContentType specifies the function to detect content types of
+	// uploaded files from their first bytes. Detected types are checked against
+	// the "types" option of the "file" struct tag, e.g.
+	// `file:"types=image/png|image/jpeg"`, and replace the client-supplied
+	// Content-Type in headers of bound files of those fields. The "ext" option
+	// restricts the filename extensions, e.g. `file:"ext=png|jpg"`. Default is
+	// http.DetectContentType.
+	DetectContentType ContentTypeDetector
+	// Checksums specifies the hash algorithms of checksums to compute for
+	// uploaded files, which are "md5", "sha1", "sha256" and "sha512". Checksums
+	// declared by the Content-MD5 and Digest headers of file parts are always
+	// verified. Computed checksums are exposed by FilePart.Checksums and
+	// StoredFile.Checksums, and replace the Digest in headers of bound files.
+	// Default is "sha256".
+	Checksums []string
+	// RejectUnsafeFilenames indicates whether to report uploaded files as errors
+	// when their filenames are changed by sanitization, e.g. containing
+	// directories, control characters or reserved device names. Default is to
+	// replace filenames of bound files with the sanitized ones, while the
+	// originals remain in the Content-Disposition of their headers.
+	RejectUnsafeFilenames bool
+	// Scanner specifies the scanner to inspect uploaded files before they are
+	// bound, and files not accepted by it are reported as errors. When the
+	// multipart form is streamed, each file part is spooled to a temporary file
+	// and scanned before being handed to FileSinks or Storage. Default is not to
+	// scan.
+	Scanner Scanner
+	// Quarantine specifies the storage to save files quarantined by Scanner into.
+	// Default is to discard them.
+	Quarantine Storage
 }
 
+// RepeatedKeysPolicy represents how to bind a repeated key into a non-slice
+// field.
+type RepeatedKeysPolicy int
+
+const (
+	// RepeatedKeysFirst binds the first value of the repeated key.
+	RepeatedKeysFirst RepeatedKeysPolicy = iota
+	// RepeatedKeysLast binds the last value of the repeated key.
+	RepeatedKeysLast
+	// RepeatedKeysReject reports an error for the repeated key and leaves the
+	// field untouched.
+	RepeatedKeysReject
+	// RepeatedKeysJoin binds all values of the repeated key joined by commas.
+	RepeatedKeysJoin
+)
+
 // errorHandlerInvoker is an inject.FastInvoker implementation of
 // `func(flamego.Context, Errors)`.
 type errorHandlerInvoker func(flamego.Context, Errors)
@@ -40,17 +189,26 @@ func (invoke errorHandlerInvoker) Invoke(args []interface{}) ([]reflect.Value, e
 	return nil, nil
 }
 
-// ensureNotPointer panics if the given value is a pointer.
+// ensureNotPointer panics if the given value, or the model type wrapped by it,
+// is a pointer.
 func ensureNotPointer(model interface{}) {
-	if reflect.TypeOf(model).Kind() == reflect.Ptr {
+	if modelType(model).Kind() == reflect.Ptr {
 		panic("binding: pointer can not be accepted as binding model")
 	}
 }
 
-// validateAndMap performs validation and then maps both the model instance and
-// any errors to the request context.
-func validateAndMap(c flamego.Context, validate *validator.Validate, obj reflect.Value, errs Errors) {
-	err := validate.VarCtx(c.Request().Context(), obj.Interface(), "dive")
+// validate performs validation of the model instance and attributes errors
+// without a source to the given source.
+func validate(ctx context.Context, opt Options, source Source, obj reflect.Value, errs Errors) Errors {
+	// The model instance has already failed validation before reading the
+	// request body.
+	for _, err := range errs {
+		if _, ok := err.Err.(validator.ValidationErrors); ok && err.Category == ErrorCategoryValidation {
+			return errs
+		}
+	}
+
+	err := opt.Validator.VarCtx(ctx, obj.Interface(), "dive")
 	if err != nil {
 		errs = append(errs,
 			Error{
@@ -59,7 +217,37 @@ func validateAndMap(c flamego.Context, validate *validator.Validate, obj reflect
 			},
 		)
 	}
-	c.Map(errs, obj.Elem().Interface())
+	for i := range errs {
+		if errs[i].Source == "" {
+			errs[i].Source = source
+		}
+	}
+	return errs
+}
+
+// validateAndMap performs validation and then maps both the model instance and
+// any errors to the request context. The model instance is mapped as the typed
+// wrapper if the model is one. Errors without a source are attributed to the
+// given source, and are appended to errors mapped by previous binders unless
+// Options.ResetErrors is set.
+func validateAndMap(c flamego.Context, opt Options, source Source, model interface{}, obj reflect.Value, errs Errors) {
+	errs = validate(c.Request().Context(), opt, source, obj, errs)
+	if !opt.ResetErrors {
+		prev := c.Value(reflect.TypeOf(errs))
+		if prev.IsValid() {
+			errs = appendErrors(prev.Interface().(Errors), errs)
+		}
+	}
+	c.Map(errs, mappedValue(model, obj))
+}
+
+// appendErrors returns a new list of errors that appends errs to prev, and it
+// returns nil if both are empty.
+func appendErrors(prev, errs Errors) Errors {
+	if len(prev) == 0 {
+		return errs
+	}
+	return append(append(make(Errors, 0, len(prev)+len(errs)), prev...), errs...)
 }
 
 func parseOptions(opts Options) Options {
@@ -76,14 +264,45 @@ func parseOptions(opts Options) Options {
 		opts.MaxMemory = 10 * 1 << 20 // 10 MiB
 	}
 
+	if opts.MaxBodyMemory <= 0 {
+		opts.MaxBodyMemory = 10 * 1 << 20 // 10 MiB
+	}
+
+	if opts.MaxDecompressedSize <= 0 {
+		opts.MaxDecompressedSize = 32 * 1 << 20 // 32 MiB
+	}
+
+	if opts.MaxDecompressionRatio <= 0 {
+		opts.MaxDecompressionRatio = 100
+	}
+
+	if opts.Checksums == nil {
+		opts.Checksums = []string{"sha256"}
+	}
+	for _, name := range opts.Checksums {
+		if _, ok := checksumHashes[name]; !ok {
+			panic(fmt.Sprintf("binding: unsupported checksum algorithm %q", name))
+		}
+	}
+
 	return opts
 }
 
-// JSON returns a middleware handler that injects a new instance of the model
-// with populated fields and binding.Errors for any deserialization, binding, or
-// validation errors into the request context. The model instance fields are
-// populated by deserializing the JSON payload from the request body.
-func JSON(model interface{}, opts ...Options) flamego.Handler {
+// bind returns a middleware handler that populates a new instance of the model
+// using the decode function, and then validates and maps it to the request
+// context along with any errors. The error handler in options is invoked if
+// there is any error. For binders that read the request body, i.e. mediaTypes
+// is not nil, the Content-Type, the Content-Length and the header-bound fields
+// are checked before reading, and the request body is prepared to be
+// replayable.
+func bind(
+	name string,
+	model interface{},
+	source Source,
+	mediaTypes []string,
+	opts []Options,
+	decode func(c flamego.Context, obj reflect.Value, opt Options) Errors,
+) flamego.Handler {
 	ensureNotPointer(model)
 
 	var opt Options
@@ -91,72 +310,169 @@ func JSON(model interface{}, opts ...Options) flamego.Handler {
 		opt = opts[0]
 	}
 	opt = parseOptions(opt)
+	// Models of typed wrappers, e.g. binding.FromBody, are only bound from their
+	// own sources.
+	var fields []requestField
+	if _, ok := model.(typedWrapper); !ok {
+		fields = headerFields(modelType(model), nil, nil)
+	}
 
 	return flamego.ContextInvoker(func(c flamego.Context) {
+		opt := opt
+		storage := trackStorage(opt.Storage)
+		if storage != nil {
+			opt.Storage = storage
+		}
+
 		var errs Errors
-		obj := reflect.New(reflect.TypeOf(model))
-		r := c.Request().Request
-		if r.Body != nil {
-			defer func() { _ = r.Body.Close() }()
-			err := json.NewDecoder(r.Body).Decode(obj.Interface())
+		var raw *RawBody
+		obj := reflect.New(modelType(model))
+		if mediaTypes != nil {
+			err := checkMediaType(c.Request().Request, opt, mediaTypes)
+			if err == nil {
+				errs = checkBeforeBody(c.Request().Request, obj, fields, opt)
+			}
+			if err == nil && len(errs) == 0 {
+				// The flamego.ResponseWriter does not expose the underlying connection
+				// to set read deadlines on.
+				raw, err = prepareBody(c.Request().Request, nil, opt)
+			}
 			if err != nil {
-				errs = append(errs,
-					Error{
-						Category: ErrorCategoryDeserialization,
-						Err:      err,
-					},
-				)
+				errs = append(errs, *err)
+			}
+			for i := range errs {
+				if errs[i].Source == "" {
+					errs[i].Source = source
+				}
 			}
+			if raw != nil {
+				c.Map(raw)
+				defer func() { _ = raw.Close() }()
+			}
+		}
+
+		// The request body is unusable when it failed to be prepared.
+		multipartForm := c.Request().MultipartForm
+		if len(errs) == 0 {
+			errs = decode(c, obj, opt)
+			if mediaTypes != nil {
+				// Header-bound fields always take values of the header that have been
+				// checked, even if the request body carries the same names.
+				_ = mapHeaderValues(obj.Elem(), fields, c.Request().Header, opt)
+			}
+		}
+		validateAndMap(c, opt, source, model, obj, errs)
+
+		// Remove temporary files of the multipart form parsed by this binder after
+		// the handler chain finishes, even if it panics.
+		cleanup := false
+		if form := c.Request().MultipartForm; form != nil && form != multipartForm && !opt.KeepMultipartFiles {
+			cleanup = true
+			defer func() { _ = form.RemoveAll() }()
+		}
+
+		// Remove files saved to the storage by this binder after the handler chain
+		// finishes if there are errors, or if the handler chain does not finish.
+		finished := false
+		stored := storage.stored()
+		if stored {
+			defer func() {
+				if len(errs) > 0 || !finished {
+					storage.removeAll()
+				}
+			}()
 		}
-		validateAndMap(c, opt.Validator, obj, errs)
 
 		errs = c.Value(reflect.TypeOf(errs)).Interface().(Errors)
 		if len(errs) > 0 && opt.ErrorHandler != nil {
 			_, err := c.Invoke(opt.ErrorHandler)
 			if err != nil {
-				panic("binding.JSON: " + err.Error())
+				panic("binding." + name + ": " + err.Error())
 			}
 		}
+
+		// Run the rest of the handler chain within this handler when the request
+		// body is buffered, the multipart form is parsed or files are stored by it,
+		// so that they are only released afterwards.
+		if (raw != nil || cleanup || stored) && !c.ResponseWriter().Written() {
+			c.Next()
+		}
+		finished = true
+	})
+}
+
+// JSON returns a middleware handler that injects a new instance of the model
+// with populated fields and binding.Errors for any deserialization, binding, or
+// validation errors into the request context. The model instance fields are
+// populated by deserializing the JSON payload from the request body.
+func JSON(model interface{}, opts ...Options) flamego.Handler {
+	return bind("JSON", model, SourceJSON, jsonMediaTypes, opts, func(c flamego.Context, obj reflect.Value, opt Options) Errors {
+		return decodeJSON(c.Request().Request, obj, opt)
 	})
 }
 
+// decodeJSON populates the object by deserializing the JSON payload from the
+// request body.
+func decodeJSON(r *http.Request, obj reflect.Value, opt Options) Errors {
+	if r.Body == nil {
+		return nil
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	body, bodyErr := textBody(r, opt)
+	if bodyErr != nil {
+		return Errors{*bodyErr}
+	}
+
+	dec := json.NewDecoder(body)
+	if opt.Strict {
+		dec.DisallowUnknownFields()
+	}
+	err := dec.Decode(obj.Interface())
+	if err != nil {
+		return Errors{bodyError(r, err)}
+	}
+
+	if opt.RejectInvalidUTF8 {
+		return checkStrings(obj, "", "json", nil)
+	}
+	return nil
+}
+
 // YAML returns a middleware handler that injects a new instance of the model
 // with populated fields and binding.Errors for any deserialization, binding, or
 // validation errors into the request context. The model instance fields are
 // populated by deserializing the YAML payload from the request body.
 func YAML(model interface{}, opts ...Options) flamego.Handler {
-	ensureNotPointer(model)
-	var opt Options
-	if len(opts) > 0 {
-		opt = opts[0]
+	return bind("YAML", model, SourceYAML, yamlMediaTypes, opts, func(c flamego.Context, obj reflect.Value, opt Options) Errors {
+		return decodeYAML(c.Request().Request, obj, opt)
+	})
+}
+
+// decodeYAML populates the object by deserializing the YAML payload from the
+// request body.
+func decodeYAML(r *http.Request, obj reflect.Value, opt Options) Errors {
+	if r.Body == nil {
+		return nil
 	}
-	opt = parseOptions(opt)
+	defer func() { _ = r.Body.Close() }()
 
-	return flamego.ContextInvoker(func(c flamego.Context) {
-		var errs Errors
-		r := c.Request().Request
-		obj := reflect.New(reflect.TypeOf(model))
-		if r.Body != nil {
-			defer func() { _ = r.Body.Close() }()
-			err := yaml.NewDecoder(r.Body).Decode(obj.Interface())
-			if err != nil {
-				errs = append(errs,
-					Error{
-						Category: ErrorCategoryDeserialization,
-						Err:      err,
-					},
-				)
-			}
-		}
-		validateAndMap(c, opt.Validator, obj, errs)
-		errs = c.Value(reflect.TypeOf(errs)).Interface().(Errors)
-		if len(errs) > 0 && opt.ErrorHandler != nil {
-			_, err := c.Invoke(opt.ErrorHandler)
-			if err != nil {
-				panic("binding.YAML: " + err.Error())
-			}
-		}
-	})
+	body, bodyErr := textBody(r, opt)
+	if bodyErr != nil {
+		return Errors{*bodyErr}
+	}
+
+	dec := yaml.NewDecoder(body)
+	dec.KnownFields(opt.Strict)
+	err := dec.Decode(obj.Interface())
+	if err != nil {
+		return Errors{bodyError(r, err)}
+	}
+
+	if opt.RejectInvalidUTF8 {
+		return checkStrings(obj, "", "yaml", nil)
+	}
+	return nil
 }
 
 // Form returns a middleware handler that injects a new instance of the model
@@ -165,46 +481,209 @@ func YAML(model interface{}, opts ...Options) flamego.Handler {
 // populated by deserializing the payload from both form-urlencoded data request
 // body and URL query parameters.
 func Form(model interface{}, opts ...Options) flamego.Handler {
-	ensureNotPointer(model)
+	return bind("Form", model, SourceForm, formMediaTypes, opts, func(c flamego.Context, obj reflect.Value, opt Options) Errors {
+		return decodeForm(c.Request().Request, obj, opt)
+	})
+}
 
-	var opt Options
-	if len(opts) > 0 {
-		opt = opts[0]
+// decodeForm populates the object by deserializing the payload from both
+// form-urlencoded data request body and URL query parameters.
+func decodeForm(r *http.Request, obj reflect.Value, opt Options) Errors {
+	var errs Errors
+	err := r.ParseForm()
+	if err != nil {
+		errs = append(errs, bodyError(r, err))
 	}
-	opt = parseOptions(opt)
 
-	return flamego.ContextInvoker(func(c flamego.Context) {
-		var errs Errors
-		r := c.Request().Request
-		err := r.ParseForm()
+	// Only the request body is sent in the charset of the Content-Type, while
+	// the URL query is always percent-encoded UTF-8.
+	postForm, formErr := transcodeForm(r, r.PostForm)
+	if formErr != nil {
+		errs = append(errs, *formErr)
+	}
+	form := make(url.Values, len(r.Form))
+	for k, vs := range postForm {
+		form[k] = append(form[k], vs...)
+	}
+	for k, vs := range r.URL.Query() {
+		form[k] = append(form[k], vs...)
+	}
+	return decodeValues(form, nil, obj, "form", true, opt, errs)
+}
+
+// Query returns a middleware handler that injects a new instance of the model
+// with populated fields and binding.Errors for any deserialization, binding, or
+// validation errors into the request context. The model instance fields are
+// populated by deserializing the payload from URL query parameters only, using
+// the "query" struct tag for field names.
+func Query(model interface{}, opts ...Options) flamego.Handler {
+	return bind("Query", model, SourceQuery, nil, opts, func(c flamego.Context, obj reflect.Value, opt Options) Errors {
+		return decodeQuery(c.Request().Request, obj, opt)
+	})
+}
+
+// decodeQuery populates the object by deserializing the payload from URL query
+// parameters.
+func decodeQuery(r *http.Request, obj reflect.Value, opt Options) Errors {
+	var errs Errors
+	form, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		errs = append(errs,
+			Error{
+				Category: ErrorCategoryDeserialization,
+				Err:      err,
+			},
+		)
+	}
+	return decodeValues(form, nil, obj, "query", true, opt, errs)
+}
+
+// Params returns a middleware handler that injects a new instance of the model
+// with populated fields and binding.Errors for any deserialization, binding, or
+// validation errors into the request context. The model instance fields are
+// populated by deserializing the bind parameters of the route, using the
+// "param" struct tag for field names.
+func Params(model interface{}, opts ...Options) flamego.Handler {
+	return bind("Params", model, SourceParams, nil, opts, func(c flamego.Context, obj reflect.Value, opt Options) Errors {
+		return decodeValues(paramValues(c.Params()), nil, obj, "param", true, opt, nil)
+	})
+}
+
+// Header returns a middleware handler that injects a new instance of the model
+// with populated fields and binding.Errors for any deserialization, binding, or
+// validation errors into the request context. The model instance fields are
+// populated by deserializing the request headers, using the "header" struct tag
+// for field names. Field names are matched case-insensitively, and
+// Options.Strict has no effect.
+func Header(model interface{}, opts ...Options) flamego.Handler {
+	return bind("Header", model, SourceHeader, nil, opts, func(c flamego.Context, obj reflect.Value, opt Options) Errors {
+		return decodeValues(url.Values(c.Request().Header), nil, obj, "header", false, opt, nil)
+	})
+}
+
+// Cookie returns a middleware handler that injects a new instance of the model
+// with populated fields and binding.Errors for any deserialization, binding, or
+// validation errors into the request context. The model instance fields are
+// populated by deserializing the request cookies, using the "cookie" struct tag
+// for field names. Cookie values are unescaped the same way as
+// flamego.Context.Cookie, and Options.Strict has no effect.
+func Cookie(model interface{}, opts ...Options) flamego.Handler {
+	return bind("Cookie", model, SourceCookie, nil, opts, func(c flamego.Context, obj reflect.Value, opt Options) Errors {
+		return decodeValues(cookieValues(c.Request().Request), nil, obj, "cookie", false, opt, nil)
+	})
+}
+
+// cookieValues returns values of cookies in the request, which are unescaped the
+// same way as flamego.Context.Cookie.
+func cookieValues(r *http.Request) url.Values {
+	form := make(url.Values)
+	for _, cookie := range r.Cookies() {
+		val, err := url.QueryUnescape(cookie.Value)
 		if err != nil {
+			val = cookie.Value
+		}
+		form.Add(cookie.Name, val)
+	}
+	return form
+}
+
+// paramValues returns bind parameters of the route.
+func paramValues(params map[string]string) url.Values {
+	form := make(url.Values, len(params))
+	for k, v := range params {
+		form.Set(k, v)
+	}
+	return form
+}
+
+// decodeValues maps the values extracted from the request into the object by
+// looking up field names from the given struct tag. Unknown keys are only
+// reported when the strict mode is both enabled and applicable to the source.
+func decodeValues(
+	form url.Values,
+	files map[string][]*multipart.FileHeader,
+	obj reflect.Value,
+	tag string,
+	strictable bool,
+	opt Options,
+	errs Errors,
+) Errors {
+	errs = mapFormWithLimits(obj, form, files, tag, opt, errs)
+	if strictable && opt.Strict {
+		errs = checkUnknownKeys(formFieldNames(obj.Type(), tag, nil), form, files, errs)
+	}
+	return errs
+}
+
+// mapFormWithLimits checks the form data against the limits of keys and values
+// in the options, and only maps the form data into the struct object when none
+// of them is exceeded.
+func mapFormWithLimits(
+	obj reflect.Value,
+	form url.Values,
+	files map[string][]*multipart.FileHeader,
+	tag string,
+	opt Options,
+	errs Errors,
+) Errors {
+	limitErrs := checkFormLimits(form, files, opt)
+	if len(limitErrs) > 0 {
+		return append(errs, limitErrs...)
+	}
+	return mapForm(obj, form, files, tag, opt, errs)
+}
+
+// checkFormLimits returns errors for the limits of keys and values in the
+// options that are exceeded by the form data.
+func checkFormLimits(form url.Values, files map[string][]*multipart.FileHeader, opt Options) Errors {
+	if opt.MaxKeys > 0 && len(form)+len(files) > opt.MaxKeys {
+		return Errors{
+			{
+				Category: ErrorCategoryDeserialization,
+				Err:      fmt.Errorf("form has %d keys which exceeds the limit %d", len(form)+len(files), opt.MaxKeys),
+			},
+		}
+	}
+	if opt.MaxValuesPerKey <= 0 {
+		return nil
+	}
+
+	counts := make(map[string]int, len(form)+len(files))
+	for key, values := range form {
+		counts[key] += len(values)
+	}
+	for key, fhs := range files {
+		counts[key] += len(fhs)
+	}
+
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs Errors
+	for _, key := range keys {
+		if counts[key] > opt.MaxValuesPerKey {
 			errs = append(errs,
 				Error{
 					Category: ErrorCategoryDeserialization,
-					Err:      err,
+					Err:      fmt.Errorf("field %q has %d values which exceeds the limit %d", key, counts[key], opt.MaxValuesPerKey),
 				},
 			)
 		}
-
-		obj := reflect.New(reflect.TypeOf(model))
-		errs = mapForm(obj, r.Form, nil, errs)
-		validateAndMap(c, opt.Validator, obj, errs)
-
-		errs = c.Value(reflect.TypeOf(errs)).Interface().(Errors)
-		if len(errs) > 0 && opt.ErrorHandler != nil {
-			_, err := c.Invoke(opt.ErrorHandler)
-			if err != nil {
-				panic("binding.Form: " + err.Error())
-			}
-		}
-	})
+	}
+	return errs
 }
 
-// mapForm takes values from the form data and maps them into the struct object.
+// mapForm takes values from the form data and maps them into the struct object
+// by looking up field names from the given struct tag.
 func mapForm(
 	obj reflect.Value,
 	form url.Values,
 	files map[string][]*multipart.FileHeader,
+	tag string,
+	opt Options,
 	errs Errors,
 ) Errors {
 	if obj.Kind() == reflect.Ptr {
@@ -221,60 +700,184 @@ func mapForm(
 
 		if typeField.Type.Kind() == reflect.Ptr && typeField.Anonymous {
 			structField.Set(reflect.New(typeField.Type.Elem()))
-			errs = mapForm(structField.Elem(), form, files, errs)
+			errs = mapForm(structField.Elem(), form, files, tag, opt, errs)
 			if reflect.DeepEqual(structField.Elem().Interface(), reflect.Zero(structField.Elem().Type()).Interface()) {
 				structField.Set(reflect.Zero(structField.Type()))
 			}
-		} else if typeField.Type.Kind() == reflect.Struct {
-			errs = mapForm(structField, form, files, errs)
-		}
-
-		fieldName := typeField.Tag.Get("form")
-		if fieldName == "" {
-			fieldName = typeField.Name
+		} else if typeField.Type.Kind() == reflect.Struct && typeField.Type != storedFileType {
+			// Stored files are only populated from the storage.
+			errs = mapForm(structField, form, files, tag, opt, errs)
 		}
 
+		fieldName := formFieldName(typeField, tag)
 		inputValue, exists := form[fieldName]
 		if exists {
-			numElems := len(inputValue)
-			if structField.Kind() == reflect.Slice && numElems > 0 {
-				sliceOf := structField.Type().Elem().Kind()
-				slice := reflect.MakeSlice(structField.Type(), numElems, numElems)
-				for i := 0; i < numElems; i++ {
-					err := setWithProperType(sliceOf, inputValue[i], slice.Index(i), fieldName)
-					if err != nil {
-						errs = append(errs, *err)
-					}
-				}
-				obj.Field(i).Set(slice)
-			} else {
-				err := setWithProperType(typeField.Type.Kind(), inputValue[0], structField, fieldName)
-				if err != nil {
-					errs = append(errs, *err)
-				}
-			}
+			errs = setFormValue(structField, inputValue, fieldName, opt, errs)
 			continue
 		}
 
 		inputFile, exists := files[fieldName]
-		if !exists {
-			continue
+		if exists {
+			setFormFile(structField, inputFile)
 		}
-		fhType := reflect.TypeOf((*multipart.FileHeader)(nil))
-		numElems := len(inputFile)
-		if structField.Kind() == reflect.Slice && numElems > 0 && structField.Type().Elem() == fhType {
-			slice := reflect.MakeSlice(structField.Type(), numElems, numElems)
-			for i := 0; i < numElems; i++ {
-				slice.Index(i).Set(reflect.ValueOf(inputFile[i]))
+	}
+	return errs
+}
+
+// setFormValue sets the list of values of the key in the form data to the
+// struct field, which is either a slice or a single value picked according to
+// the policy of repeated keys.
+func setFormValue(structField reflect.Value, inputValue []string, fieldName string, opt Options, errs Errors) Errors {
+	numElems := len(inputValue)
+	if structField.Kind() == reflect.Slice && numElems > 0 {
+		sliceOf := structField.Type().Elem().Kind()
+		slice := reflect.MakeSlice(structField.Type(), numElems, numElems)
+		for i := 0; i < numElems; i++ {
+			var err *Error
+			if sliceOf == reflect.String && opt.RejectInvalidUTF8 {
+				err = checkText(fieldName, inputValue[i])
+			}
+			if err == nil {
+				err = setWithProperType(sliceOf, inputValue[i], slice.Index(i), fieldName)
+			}
+			if err != nil {
+				errs = append(errs, *err)
 			}
-			structField.Set(slice)
-		} else if structField.Type() == fhType {
-			structField.Set(reflect.ValueOf(inputFile[0]))
 		}
+		structField.Set(slice)
+		return errs
+	}
+
+	val, err := pickRepeatedValue(inputValue, fieldName, opt.RepeatedKeys)
+	if err == nil && structField.Kind() == reflect.String && opt.RejectInvalidUTF8 {
+		err = checkText(fieldName, val)
+	}
+	if err == nil {
+		err = setWithProperType(structField.Kind(), val, structField, fieldName)
+	}
+	if err != nil {
+		errs = append(errs, *err)
 	}
 	return errs
 }
 
+// setFormFile sets the list of uploaded files of the key in the form data to
+// the struct field if it is either a *multipart.FileHeader or a slice of it.
+func setFormFile(structField reflect.Value, inputFile []*multipart.FileHeader) {
+	fhType := reflect.TypeOf((*multipart.FileHeader)(nil))
+	numElems := len(inputFile)
+	if structField.Kind() == reflect.Slice && numElems > 0 && structField.Type().Elem() == fhType {
+		slice := reflect.MakeSlice(structField.Type(), numElems, numElems)
+		for i := 0; i < numElems; i++ {
+			slice.Index(i).Set(reflect.ValueOf(inputFile[i]))
+		}
+		structField.Set(slice)
+	} else if structField.Type() == fhType {
+		structField.Set(reflect.ValueOf(inputFile[0]))
+	}
+}
+
+// formFieldName returns the name to look up in the form data for the field from
+// the given struct tag, and falls back to the field name when the tag is absent.
+func formFieldName(field reflect.StructField, tag string) string {
+	name := field.Tag.Get(tag)
+	if name == "" {
+		name = field.Name
+	}
+	if tag == "header" {
+		name = textproto.CanonicalMIMEHeaderKey(name)
+	}
+	return name
+}
+
+// formFieldNames collects names of all fields that mapForm would look up in the
+// form data for the given struct type and struct tag into the set.
+func formFieldNames(typ reflect.Type, tag string, names map[string]struct{}) map[string]struct{} {
+	if names == nil {
+		names = make(map[string]struct{})
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return names
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		typeField := typ.Field(i)
+		if typeField.PkgPath != "" {
+			continue // Unexported fields are never set by mapForm
+		}
+
+		if typeField.Type.Kind() == reflect.Ptr && typeField.Anonymous {
+			formFieldNames(typeField.Type.Elem(), tag, names)
+		} else if typeField.Type.Kind() == reflect.Struct && typeField.Type != storedFileType {
+			formFieldNames(typeField.Type, tag, names)
+		}
+		names[formFieldName(typeField, tag)] = struct{}{}
+	}
+	return names
+}
+
+// checkUnknownKeys reports every key in the form data that is not in the set of
+// field names as an error, except the "_charset_" field sent by browsers.
+func checkUnknownKeys(
+	fieldNames map[string]struct{},
+	form url.Values,
+	files map[string][]*multipart.FileHeader,
+	errs Errors,
+) Errors {
+	var unknown []string
+	for key := range form {
+		if key == charsetField {
+			continue
+		}
+		if _, ok := fieldNames[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	for key := range files {
+		if _, ok := form[key]; ok {
+			continue // Already reported
+		}
+		if _, ok := fieldNames[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs,
+			Error{
+				Category: ErrorCategoryDeserialization,
+				Err:      fmt.Errorf("unknown field %q", key),
+			},
+		)
+	}
+	return errs
+}
+
+// pickRepeatedValue returns the value to be bound into a non-slice field from
+// the list of values of the key according to the given policy.
+func pickRepeatedValue(values []string, name string, policy RepeatedKeysPolicy) (string, *Error) {
+	if len(values) == 1 {
+		return values[0], nil
+	}
+
+	switch policy {
+	case RepeatedKeysLast:
+		return values[len(values)-1], nil
+	case RepeatedKeysReject:
+		return "", &Error{
+			Category: ErrorCategoryDeserialization,
+			Err:      fmt.Errorf("field %q has %d values but accepts only one", name, len(values)),
+		}
+	case RepeatedKeysJoin:
+		return strings.Join(values, ","), nil
+	}
+	return values[0], nil
+}
+
 // setWithProperType sets the value of an indeterminate type to the matching
 // value from the request in the same type, so that not all deserialized values
 // have to be strings. Supported types are int, uint, bool, float and string.
@@ -366,55 +969,80 @@ func setWithProperType(kind reflect.Kind, val string, field reflect.Value, name
 // binding, or validation errors into the request context. It works much like
 // binding.Form except it can parse multipart forms and handle file uploads.
 func MultipartForm(model interface{}, opts ...Options) flamego.Handler {
-	ensureNotPointer(model)
+	return bind("MultipartForm", model, SourceMultipartForm, multipartFormMediaTypes, opts, func(c flamego.Context, obj reflect.Value, opt Options) Errors {
+		return decodeMultipartForm(c.Request().Request, obj, opt)
+	})
+}
 
-	var opt Options
-	if len(opts) > 0 {
-		opt = opts[0]
+// decodeMultipartForm populates the object by deserializing the multipart form
+// from the request body.
+func decodeMultipartForm(r *http.Request, obj reflect.Value, opt Options) Errors {
+	if (opt.StreamMultipart || opt.Storage != nil) && r.MultipartForm == nil {
+		return streamMultipartForm(r, obj, opt)
 	}
-	opt = parseOptions(opt)
-
-	return flamego.ContextInvoker(func(c flamego.Context) {
-		var errs Errors
-		r := c.Request().Request
 
-		// Only parse the form if it has not yet been parsed, see
-		// https://github.com/martini-contrib/csrf/issues/6
-		if r.MultipartForm == nil {
-			mr, err := r.MultipartReader()
-			if err != nil {
-				errs = append(errs,
-					Error{
-						Category: ErrorCategoryDeserialization,
-						Err:      err,
-					},
-				)
-			} else {
-				form, err := mr.ReadForm(opt.MaxMemory)
-				if err != nil {
-					errs = append(errs,
-						Error{
-							Category: ErrorCategoryDeserialization,
-							Err:      err,
-						},
-					)
-				}
-				r.MultipartForm = form
-			}
+	errs, ok := readMultipartForm(r, obj, formFieldNames(obj.Type(), "form", nil), opt)
+	if ok && r.MultipartForm != nil {
+		form, formErr := transcodeForm(r, r.MultipartForm.Value)
+		if formErr != nil {
+			errs = append(errs, *formErr)
 		}
+		errs = decodeValues(form, r.MultipartForm.File, obj, "form", true, opt, errs)
+	}
+	return errs
+}
 
-		obj := reflect.New(reflect.TypeOf(model))
-		if r.MultipartForm != nil {
-			errs = mapForm(obj, r.MultipartForm.Value, r.MultipartForm.File, errs)
+// readMultipartForm parses the request body as multipart form into
+// r.MultipartForm, enforcing the upload limits of the options and the "file"
+// struct tags of the object, and then inspects uploaded files of the form fields
+// in the set of field names. It returns false when the form is rejected as a
+// whole by the limits.
+func readMultipartForm(r *http.Request, obj reflect.Value, fieldNames map[string]struct{}, opt Options) (Errors, bool) {
+	var errs Errors
+	limiter := newUploadLimiter(obj, opt)
+	if r.MultipartForm == nil && limiter.limited() {
+		limitErr, err := limiter.readForm(r, opt.MaxMemory)
+		if limitErr != nil {
+			return Errors{*limitErr}, false
+		} else if err != nil {
+			errs = append(errs, bodyError(r, err))
+		}
+	} else {
+		err := parseMultipartForm(r, opt.MaxMemory)
+		if err != nil {
+			errs = append(errs, bodyError(r, err))
 		}
-		validateAndMap(c, opt.Validator, obj, errs)
 
-		errs = c.Value(reflect.TypeOf(errs)).Interface().(Errors)
-		if len(errs) > 0 && opt.ErrorHandler != nil {
-			_, err := c.Invoke(opt.ErrorHandler)
-			if err != nil {
-				panic("binding.MultipartForm: " + err.Error())
+		// The form that has already been parsed can only be checked as a whole.
+		if r.MultipartForm != nil {
+			limitErrs := limiter.checkForm(r.MultipartForm)
+			if len(limitErrs) > 0 {
+				return append(errs, limitErrs...), false
 			}
 		}
-	})
+	}
+
+	if r.MultipartForm != nil {
+		errs = append(errs, inspectFiles(r.Context(), r.MultipartForm, fieldNames, limiter.fields, opt)...)
+	}
+	return errs, true
+}
+
+// parseMultipartForm parses the request body as multipart form, and stores the
+// result to r.MultipartForm.
+func parseMultipartForm(r *http.Request, maxMemory int64) error {
+	// Only parse the form if it has not yet been parsed, see
+	// https://github.com/martini-contrib/csrf/issues/6
+	if r.MultipartForm != nil {
+		return nil
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+
+	form, err := mr.ReadForm(maxMemory)
+	r.MultipartForm = form
+	return err
 }
@@ -12,6 +12,8 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -54,12 +56,40 @@ func TestJSON(t *testing.T) {
 		want := Errors{
 			{
 				Category: ErrorCategoryDeserialization,
+				Source:   SourceJSON,
 				Err:      errors.New("unexpected EOF"),
 			},
 		}
 		assert.Equal(t, want, got)
 	})
 
+	t.Run("strict", func(t *testing.T) {
+		type form struct {
+			Username string
+		}
+
+		var got Errors
+		f := flamego.New()
+		f.Post("/", JSON(form{}, Options{Strict: true}), func(errs Errors) {
+			got = errs
+		})
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"Username": "alice", "Admin": true}`))
+		assert.Nil(t, err)
+
+		f.ServeHTTP(resp, req)
+
+		want := Errors{
+			{
+				Category: ErrorCategoryDeserialization,
+				Source:   SourceJSON,
+				Err:      errors.New(`json: unknown field "Admin"`),
+			},
+		}
+		assert.Equal(t, want, got)
+	})
+
 	t.Run("custom error handler", func(t *testing.T) {
 		type form struct {
 			Username string `validate:"required"`
@@ -198,7 +228,7 @@ func TestJSON(t *testing.T) {
 				assert.Len(t, errs, 1)
 
 				got := fmt.Sprintf("%v", errs[0])
-				want := `{validation Key: "user.FirstName" Error: Field validation for "FirstName" failed on the "required" tag}`
+				want := `{validation Key: "user.FirstName" Error: Field validation for "FirstName" failed on the "required" tag json}`
 				assert.Equal(t, want, got)
 			},
 		},
@@ -222,7 +252,7 @@ func TestJSON(t *testing.T) {
 				assert.Len(t, errs, 1)
 
 				got := fmt.Sprintf("%v", errs[0])
-				want := `{validation Key: "user.Age" Error: Field validation for "Age" failed on the "lte" tag}`
+				want := `{validation Key: "user.Age" Error: Field validation for "Age" failed on the "lte" tag json}`
 				assert.Equal(t, want, got)
 			},
 		},
@@ -314,7 +344,7 @@ func TestJSON(t *testing.T) {
 					assert.Len(t, errs, 1)
 
 					got := fmt.Sprintf("%v", errs[0])
-					want := `{validation Key: "[0].FirstName" Error: Field validation for "FirstName" failed on the "required" tag}`
+					want := `{validation Key: "[0].FirstName" Error: Field validation for "FirstName" failed on the "required" tag json}`
 					assert.Equal(t, want, got)
 				},
 			},
@@ -381,12 +411,35 @@ func TestYAML(t *testing.T) {
 		want := Errors{
 			{
 				Category: ErrorCategoryDeserialization,
+				Source:   SourceYAML,
 				Err:      errors.New("yaml: line 1: did not find expected node content"),
 			},
 		}
 		assert.Equal(t, want, got)
 	})
 
+	t.Run("strict", func(t *testing.T) {
+		type body struct {
+			Username string `yaml:"username"`
+		}
+
+		var got Errors
+		f := flamego.New()
+		f.Post("/", YAML(body{}, Options{Strict: true}), func(errs Errors) {
+			got = errs
+		})
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString("username: alice\nadmin: true"))
+		assert.Nil(t, err)
+
+		f.ServeHTTP(resp, req)
+
+		assert.Len(t, got, 1)
+		assert.Equal(t, ErrorCategoryDeserialization, got[0].Category)
+		assert.Contains(t, got[0].Err.Error(), "field admin not found")
+	})
+
 	t.Run("custom error handler", func(t *testing.T) {
 		type yaml struct {
 			Username string `validate:"required" yaml:"Username"`
@@ -565,7 +618,7 @@ address:
 				assert.Len(t, errs, 1)
 
 				got := fmt.Sprintf("%v", errs[0])
-				want := "{deserialization yaml: unmarshal errors:\n  line 5: cannot unmarshal !!str `bad` into int}"
+				want := "{deserialization yaml: unmarshal errors:\n  line 5: cannot unmarshal !!str `bad` into int yaml}"
 				assert.Equal(t, want, got)
 			},
 		},
@@ -605,7 +658,7 @@ address:
 				assert.Len(t, errs, 1)
 
 				got := fmt.Sprintf("%v", errs[0])
-				want := "{deserialization yaml: unmarshal errors:\n  line 4: cannot unmarshal !!str `bad` into uint8}"
+				want := "{deserialization yaml: unmarshal errors:\n  line 4: cannot unmarshal !!str `bad` into uint8 yaml}"
 				assert.Equal(t, want, got)
 			},
 		},
@@ -645,7 +698,7 @@ address:
 				assert.Len(t, errs, 1)
 
 				got := fmt.Sprintf("%v", errs[0])
-				want := "{deserialization yaml: unmarshal errors:\n  line 6: cannot unmarshal !!str `bad` into bool}"
+				want := "{deserialization yaml: unmarshal errors:\n  line 6: cannot unmarshal !!str `bad` into bool yaml}"
 				assert.Equal(t, want, got)
 			},
 		},
@@ -685,7 +738,7 @@ address:
 				assert.Len(t, errs, 1)
 
 				got := fmt.Sprintf("%v", errs[0])
-				want := "{deserialization yaml: unmarshal errors:\n  line 8: cannot unmarshal !!str `bad` into float32}"
+				want := "{deserialization yaml: unmarshal errors:\n  line 8: cannot unmarshal !!str `bad` into float32 yaml}"
 				assert.Equal(t, want, got)
 			},
 		},
@@ -725,7 +778,7 @@ address:
 				assert.Len(t, errs, 1)
 
 				got := fmt.Sprintf("%v", errs[0])
-				want := "{deserialization yaml: unmarshal errors:\n  line 9: cannot unmarshal !!str `bad` into float64}"
+				want := "{deserialization yaml: unmarshal errors:\n  line 9: cannot unmarshal !!str `bad` into float64 yaml}"
 				assert.Equal(t, want, got)
 			},
 		},
@@ -989,7 +1042,7 @@ func TestForm(t *testing.T) {
 				assert.Len(t, errs, 1)
 
 				got := fmt.Sprintf("%v", errs[0])
-				want := `{deserialization field "height" cannot parse "bad" as int}`
+				want := `{deserialization field "height" cannot parse "bad" as int form}`
 				assert.Equal(t, want, got)
 			},
 		},
@@ -1017,7 +1070,7 @@ func TestForm(t *testing.T) {
 				assert.Len(t, errs, 1)
 
 				got := fmt.Sprintf("%v", errs[0])
-				want := `{deserialization field "age" cannot parse "bad" as uint}`
+				want := `{deserialization field "age" cannot parse "bad" as uint form}`
 				assert.Equal(t, want, got)
 			},
 		},
@@ -1045,7 +1098,7 @@ func TestForm(t *testing.T) {
 				assert.Len(t, errs, 1)
 
 				got := fmt.Sprintf("%v", errs[0])
-				want := `{deserialization field "male" cannot parse "bad" as bool}`
+				want := `{deserialization field "male" cannot parse "bad" as bool form}`
 				assert.Equal(t, want, got)
 			},
 		},
@@ -1073,7 +1126,7 @@ func TestForm(t *testing.T) {
 				assert.Len(t, errs, 1)
 
 				got := fmt.Sprintf("%v", errs[0])
-				want := `{deserialization field "weight" cannot parse "bad" as float32}`
+				want := `{deserialization field "weight" cannot parse "bad" as float32 form}`
 				assert.Equal(t, want, got)
 			},
 		},
@@ -1101,7 +1154,7 @@ func TestForm(t *testing.T) {
 				assert.Len(t, errs, 1)
 
 				got := fmt.Sprintf("%v", errs[0])
-				want := `{deserialization field "balance" cannot parse "bad" as float64}`
+				want := `{deserialization field "balance" cannot parse "bad" as float64 form}`
 				assert.Equal(t, want, got)
 			},
 		},
@@ -1177,6 +1230,351 @@ func TestForm(t *testing.T) {
 			assert.Equal(t, test.want, gotForm)
 		})
 	}
+
+	t.Run("repeated keys", func(t *testing.T) {
+		type form struct {
+			Name string   `form:"name"`
+			Tags []string `form:"tag"`
+		}
+
+		tests := []struct {
+			name     string
+			policy   RepeatedKeysPolicy
+			want     form
+			wantErrs []string
+		}{
+			{
+				name:   "first",
+				policy: RepeatedKeysFirst,
+				want:   form{Name: "alice", Tags: []string{"a", "b"}},
+			},
+			{
+				name:   "last",
+				policy: RepeatedKeysLast,
+				want:   form{Name: "bob", Tags: []string{"a", "b"}},
+			},
+			{
+				name:     "reject",
+				policy:   RepeatedKeysReject,
+				want:     form{Tags: []string{"a", "b"}},
+				wantErrs: []string{`field "name" has 2 values but accepts only one`},
+			},
+			{
+				name:   "join",
+				policy: RepeatedKeysJoin,
+				want:   form{Name: "alice,bob", Tags: []string{"a", "b"}},
+			},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				var gotForm form
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", Form(form{}, Options{RepeatedKeys: test.policy}), func(form form, errs Errors) {
+					gotForm = form
+					gotErrs = errs
+				})
+
+				resp := httptest.NewRecorder()
+				req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`name=alice&name=bob&tag=a&tag=b`))
+				assert.Nil(t, err)
+
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				f.ServeHTTP(resp, req)
+
+				var got []string
+				for _, err := range gotErrs {
+					got = append(got, err.Err.Error())
+				}
+				assert.Equal(t, test.wantErrs, got)
+				assert.Equal(t, test.want, gotForm)
+			})
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		type address struct {
+			City string `form:"city"`
+		}
+		type form struct {
+			Name    string `form:"name"`
+			Address address
+		}
+
+		var gotForm form
+		var gotErrs Errors
+		f := flamego.New()
+		f.Post("/", Form(form{}, Options{Strict: true}), func(form form, errs Errors) {
+			gotForm = form
+			gotErrs = errs
+		})
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/?debug=1", bytes.NewBufferString(`name=alice&city=Browser&admin=true`))
+		assert.Nil(t, err)
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		f.ServeHTTP(resp, req)
+
+		want := Errors{
+			{
+				Category: ErrorCategoryDeserialization,
+				Source:   SourceForm,
+				Err:      errors.New(`unknown field "admin"`),
+			},
+			{
+				Category: ErrorCategoryDeserialization,
+				Source:   SourceForm,
+				Err:      errors.New(`unknown field "debug"`),
+			},
+		}
+		assert.Equal(t, want, gotErrs)
+		assert.Equal(t, form{Name: "alice", Address: address{City: "Browser"}}, gotForm)
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		type form struct {
+			Name string   `form:"name"`
+			Tags []string `form:"tag"`
+		}
+
+		tests := []struct {
+			name     string
+			opts     Options
+			want     form
+			wantErrs []string
+		}{
+			{
+				name: "within limits",
+				opts: Options{MaxKeys: 2, MaxValuesPerKey: 3},
+				want: form{Name: "alice", Tags: []string{"a", "b", "c"}},
+			},
+			{
+				name:     "too many keys",
+				opts:     Options{MaxKeys: 1},
+				wantErrs: []string{`form has 2 keys which exceeds the limit 1`},
+			},
+			{
+				name:     "too many values",
+				opts:     Options{MaxValuesPerKey: 2},
+				wantErrs: []string{`field "tag" has 3 values which exceeds the limit 2`},
+			},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				var gotForm form
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", Form(form{}, test.opts), func(form form, errs Errors) {
+					gotForm = form
+					gotErrs = errs
+				})
+
+				resp := httptest.NewRecorder()
+				req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`name=alice&tag=a&tag=b&tag=c`))
+				assert.Nil(t, err)
+
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				f.ServeHTTP(resp, req)
+
+				var got []string
+				for _, err := range gotErrs {
+					got = append(got, err.Err.Error())
+				}
+				assert.Equal(t, test.wantErrs, got)
+				assert.Equal(t, test.want, gotForm)
+			})
+		}
+	})
+}
+
+func TestQuery(t *testing.T) {
+	t.Run("pointer model", func(t *testing.T) {
+		assert.PanicsWithValue(t,
+			"binding: pointer can not be accepted as binding model",
+			func() {
+				type query struct {
+					Page int
+				}
+				Query(&query{})
+			},
+		)
+	})
+
+	type query struct {
+		Page  int      `query:"page" validate:"gte=1"`
+		Sort  string   `query:"sort"`
+		Flags []string `query:"flag"`
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		opts     Options
+		want     query
+		wantErrs []string
+	}{
+		{
+			name: "good",
+			url:  "/?page=2&sort=name&flag=a&flag=b",
+			want: query{Page: 2, Sort: "name", Flags: []string{"a", "b"}},
+		},
+		{
+			name: "ignore body",
+			url:  "/?page=2",
+			want: query{Page: 2},
+		},
+		{
+			name:     "bad int",
+			url:      "/?page=bad",
+			wantErrs: []string{`field "page" cannot parse "bad" as int`, `Key: "query.Page" Error: Field validation for "Page" failed on the "gte" tag`},
+		},
+		{
+			name:     "strict",
+			url:      "/?page=1&debug=true",
+			opts:     Options{Strict: true},
+			want:     query{Page: 1},
+			wantErrs: []string{`unknown field "debug"`},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotQuery query
+			var gotErrs Errors
+			f := flamego.New()
+			f.Post("/", Query(query{}, test.opts), func(query query, errs Errors) {
+				gotQuery = query
+				gotErrs = errs
+			})
+
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, test.url, bytes.NewBufferString(`sort=body`))
+			assert.Nil(t, err)
+
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			f.ServeHTTP(resp, req)
+
+			var got []string
+			for _, err := range gotErrs {
+				got = append(got, err.Err.Error())
+			}
+			assert.Equal(t, test.wantErrs, got)
+			assert.Equal(t, test.want, gotQuery)
+		})
+	}
+}
+
+func TestParams(t *testing.T) {
+	type params struct {
+		Owner string `param:"owner" validate:"required"`
+		ID    int64  `param:"id"`
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		want     params
+		wantErrs []string
+	}{
+		{
+			name: "good",
+			url:  "/flamego/issues/42",
+			want: params{Owner: "flamego", ID: 42},
+		},
+		{
+			name:     "bad int",
+			url:      "/flamego/issues/abc",
+			want:     params{Owner: "flamego"},
+			wantErrs: []string{`field "id" cannot parse "abc" as int`},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotParams params
+			var gotErrs Errors
+			f := flamego.New()
+			f.Get("/{owner}/issues/{id}", Params(params{}), func(params params, errs Errors) {
+				gotParams = params
+				gotErrs = errs
+			})
+
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, test.url, nil)
+			assert.Nil(t, err)
+
+			f.ServeHTTP(resp, req)
+
+			var got []string
+			for _, err := range gotErrs {
+				got = append(got, err.Err.Error())
+			}
+			assert.Equal(t, test.wantErrs, got)
+			assert.Equal(t, test.want, gotParams)
+		})
+	}
+}
+
+func TestHeader(t *testing.T) {
+	type header struct {
+		Token     string   `header:"authorization" validate:"required"`
+		RequestID string   `header:"X-Request-ID"`
+		Accept    []string `header:"Accept"`
+	}
+
+	var gotHeader header
+	var gotErrs Errors
+	f := flamego.New()
+	f.Get("/", Header(header{}, Options{Strict: true}), func(header header, errs Errors) {
+		gotHeader = header
+		gotErrs = errs
+	})
+
+	resp := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/", nil)
+	assert.Nil(t, err)
+
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("X-Request-Id", "1234")
+	req.Header.Add("Accept", "text/html")
+	req.Header.Add("Accept", "application/json")
+	req.Header.Set("User-Agent", "test")
+	f.ServeHTTP(resp, req)
+
+	assert.Len(t, gotErrs, 0)
+	want := header{
+		Token:     "Bearer token",
+		RequestID: "1234",
+		Accept:    []string{"text/html", "application/json"},
+	}
+	assert.Equal(t, want, gotHeader)
+}
+
+func TestCookie(t *testing.T) {
+	type cookie struct {
+		Session string `cookie:"session" validate:"required"`
+		Theme   string `cookie:"theme"`
+		Visits  int    `cookie:"visits"`
+	}
+
+	var gotCookie cookie
+	var gotErrs Errors
+	f := flamego.New()
+	f.Get("/", Cookie(cookie{}), func(cookie cookie, errs Errors) {
+		gotCookie = cookie
+		gotErrs = errs
+	})
+
+	resp := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/", nil)
+	assert.Nil(t, err)
+
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	req.AddCookie(&http.Cookie{Name: "theme", Value: "dark%20blue"})
+	req.AddCookie(&http.Cookie{Name: "visits", Value: "3"})
+	f.ServeHTTP(resp, req)
+
+	assert.Len(t, gotErrs, 0)
+	assert.Equal(t, cookie{Session: "abc", Theme: "dark blue", Visits: 3}, gotCookie)
 }
 
 func TestMultipartForm(t *testing.T) {
@@ -1329,3 +1727,146 @@ func TestMultipartForm(t *testing.T) {
 	assert.NotNil(t, gotForm.Background)
 	assert.Len(t, gotForm.Pictures, 2)
 }
+
+func TestChainedBinders(t *testing.T) {
+	type query struct {
+		Page int `query:"page"`
+	}
+	type body struct {
+		Title string `json:"title" validate:"required"`
+	}
+
+	tests := []struct {
+		name        string
+		resetErrors bool
+		want        []Error
+	}{
+		{
+			name: "accumulate",
+			want: []Error{
+				{Category: ErrorCategoryDeserialization, Source: SourceQuery},
+				{Category: ErrorCategoryValidation, Source: SourceJSON},
+			},
+		},
+		{
+			name:        "reset",
+			resetErrors: true,
+			want: []Error{
+				{Category: ErrorCategoryValidation, Source: SourceJSON},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotErrs Errors
+			f := flamego.New()
+			f.Post("/",
+				Query(query{}),
+				JSON(body{}, Options{ResetErrors: test.resetErrors}),
+				func(errs Errors) {
+					gotErrs = errs
+				},
+			)
+
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, "/?page=bad", bytes.NewBufferString(`{}`))
+			assert.Nil(t, err)
+
+			f.ServeHTTP(resp, req)
+
+			var got []Error
+			for _, err := range gotErrs {
+				got = append(got, Error{Category: err.Category, Source: err.Source})
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+
+	t.Run("no errors", func(t *testing.T) {
+		var gotErrs Errors
+		f := flamego.New()
+		f.Post("/", Query(query{}), JSON(body{}), func(errs Errors) {
+			gotErrs = errs
+		})
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/?page=1", bytes.NewBufferString(`{"title": "Hello"}`))
+		assert.Nil(t, err)
+
+		f.ServeHTTP(resp, req)
+		assert.Nil(t, gotErrs)
+	})
+}
+
+func TestMultipartFormCleanup(t *testing.T) {
+	type form struct {
+		Document *multipart.FileHeader `form:"document"`
+	}
+
+	newRequest := func(t *testing.T) *http.Request {
+		return newMultipartRequest(t, testPart{field: "document", filename: "report.txt", content: strings.Repeat("a", 1024)})
+	}
+
+	// tempFile returns the name of the temporary file of the uploaded file, which
+	// is spilled to disk because of the small Options.MaxMemory.
+	tempFile := func(t *testing.T, fh *multipart.FileHeader) string {
+		f, err := fh.Open()
+		assert.Nil(t, err)
+		defer func() { _ = f.Close() }()
+		osFile, ok := f.(*os.File)
+		assert.True(t, ok)
+		return osFile.Name()
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		panics   bool
+		wantKept bool
+	}{
+		{
+			name: "removed",
+			opts: Options{MaxMemory: 1},
+		},
+		{
+			name:   "removed on panic",
+			opts:   Options{MaxMemory: 1},
+			panics: true,
+		},
+		{
+			name:     "kept",
+			opts:     Options{MaxMemory: 1, KeepMultipartFiles: true},
+			wantKept: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var name string
+			f := flamego.New()
+			f.Post("/", MultipartForm(form{}, test.opts), func(form form, errs Errors) {
+				assert.Nil(t, errs)
+				name = tempFile(t, form.Document)
+
+				_, err := os.Stat(name)
+				assert.Nil(t, err)
+				if test.panics {
+					panic("oops")
+				}
+			})
+
+			req := newRequest(t)
+			func() {
+				defer func() {
+					assert.Equal(t, test.panics, recover() != nil)
+				}()
+				f.ServeHTTP(httptest.NewRecorder(), req)
+			}()
+
+			_, err := os.Stat(name)
+			assert.Equal(t, test.wantKept, err == nil)
+			if test.wantKept {
+				assert.Nil(t, req.MultipartForm.RemoveAll())
+			}
+		})
+	}
+}
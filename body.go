@@ -0,0 +1,159 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// RawBody is the buffered request body that can be read for any number of
+// times, it is kept in memory up to Options.MaxBodyMemory and the rest is
+// spilled to a temporary file.
+type RawBody struct {
+	buf  []byte
+	file *os.File
+	size int64
+
+	closeOnce sync.Once
+}
+
+// newRawBody buffers all content of the reader.
+func newRawBody(r io.Reader, maxMemory int64) (*RawBody, error) {
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, r, maxMemory+1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if n <= maxMemory {
+		return &RawBody{buf: buf.Bytes(), size: n}, nil
+	}
+
+	file, err := os.CreateTemp("", "binding-body-")
+	if err != nil {
+		return nil, err
+	}
+	body := &RawBody{file: file}
+	body.size, err = io.Copy(file, io.MultiReader(&buf, r))
+	if err != nil {
+		_ = body.Close()
+		return nil, err
+	}
+	return body, nil
+}
+
+// Size returns the size of the request body in bytes.
+func (b *RawBody) Size() int64 {
+	return b.size
+}
+
+// Bytes returns the content of the request body, which is read from the
+// temporary file if the body is spilled to disk.
+func (b *RawBody) Bytes() ([]byte, error) {
+	if b.file == nil {
+		return b.buf, nil
+	}
+	return io.ReadAll(b.Reader())
+}
+
+// Reader returns a new reader of the request body from the beginning.
+func (b *RawBody) Reader() io.Reader {
+	if b.file == nil {
+		return bytes.NewReader(b.buf)
+	}
+	return io.NewSectionReader(b.file, 0, b.size)
+}
+
+// Close removes the temporary file if the body is spilled to disk. It is called
+// automatically after the handler chain finishes.
+func (b *RawBody) Close() (err error) {
+	b.closeOnce.Do(func() {
+		if b.file == nil {
+			return
+		}
+		err = b.file.Close()
+		if rmErr := os.Remove(b.file.Name()); err == nil {
+			err = rmErr
+		}
+	})
+	return err
+}
+
+// replayBody is the request body that reads from the buffered body, closing it
+// does not release the buffered body.
+type replayBody struct {
+	io.Reader
+	raw *RawBody
+}
+
+func (*replayBody) Close() error {
+	return nil
+}
+
+// BufferedBody returns the buffered request body if any binder has buffered it
+// with Options.BufferBody.
+func BufferedBody(r *http.Request) (*RawBody, bool) {
+	body, ok := r.Body.(*replayBody)
+	if !ok {
+		return nil, false
+	}
+	return body.raw, true
+}
+
+// prepareBody rewinds the request body if it has already been buffered, or
+// otherwise limits its read time by Options.ReadTimeout and
+// Options.MinReadRate, limits its size by Options.MaxBodySize, decompresses it
+// according to the Content-Encoding and buffers it when Options.BufferBody is
+// set. The response writer, if not nil, is used to set read deadlines on the
+// underlying connection. It returns the buffered body only when it is
+// newly created, and the caller is responsible for closing it.
+func prepareBody(r *http.Request, w http.ResponseWriter, opt Options) (*RawBody, *Error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+
+	if raw, ok := BufferedBody(r); ok {
+		r.Body = &replayBody{Reader: raw.Reader(), raw: raw}
+		return nil, nil
+	}
+
+	switch r.Body.(type) {
+	case *timeoutBody, *limitedBody, *decompressBody:
+		// Already prepared by previous binders.
+	default:
+		if opt.ReadTimeout > 0 || opt.MinReadRate > 0 {
+			r.Body = newTimeoutBody(r, w, opt)
+		}
+		if opt.MaxBodySize > 0 {
+			r.Body = &limitedBody{
+				src:       r.Body,
+				limit:     opt.MaxBodySize,
+				remaining: opt.MaxBodySize,
+			}
+		}
+	}
+
+	if err := decompressRequestBody(r, opt); err != nil {
+		_ = r.Body.Close()
+		r.Body = http.NoBody
+		return nil, err
+	}
+	if !opt.BufferBody {
+		return nil, nil
+	}
+
+	raw, err := newRawBody(r.Body, opt.MaxBodyMemory)
+	_ = r.Body.Close()
+	if err != nil {
+		bodyErr := bodyError(r, err)
+		r.Body = http.NoBody
+		return nil, &bodyErr
+	}
+	r.Body = &replayBody{Reader: raw.Reader(), raw: raw}
+	return raw, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+func TestBufferBody(t *testing.T) {
+	type meta struct {
+		ID int `json:"id"`
+	}
+	type form struct {
+		Username string `json:"username"`
+	}
+	const payload = `{"id": 1, "username": "alice"}`
+
+	tests := []struct {
+		name          string
+		maxBodyMemory int64
+		spilled       bool
+	}{
+		{
+			name: "in memory",
+		},
+		{
+			name:          "spilled to disk",
+			maxBodyMemory: 8,
+			spilled:       true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotMeta meta
+			var gotForm form
+			var gotErrs Errors
+			var gotRaw *RawBody
+			f := flamego.New()
+			f.Post("/",
+				JSON(meta{}, Options{BufferBody: true, MaxBodyMemory: test.maxBodyMemory}),
+				JSON(form{}),
+				func(c flamego.Context, raw *RawBody, meta meta, form form, errs Errors) {
+					gotMeta = meta
+					gotForm = form
+					gotErrs = errs
+					gotRaw = raw
+
+					assert.Equal(t, int64(len(payload)), raw.Size())
+					assert.Equal(t, test.spilled, raw.file != nil)
+
+					data, err := raw.Bytes()
+					assert.Nil(t, err)
+					assert.Equal(t, payload, string(data))
+
+					data, err = io.ReadAll(raw.Reader())
+					assert.Nil(t, err)
+					assert.Equal(t, payload, string(data))
+				},
+			)
+
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(payload))
+			assert.Nil(t, err)
+
+			f.ServeHTTP(resp, req)
+
+			assert.Nil(t, gotErrs)
+			assert.Equal(t, meta{ID: 1}, gotMeta)
+			assert.Equal(t, form{Username: "alice"}, gotForm)
+
+			if test.spilled {
+				_, err = os.Stat(gotRaw.file.Name())
+				assert.True(t, os.IsNotExist(err))
+			}
+		})
+	}
+
+	t.Run("net/http", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(payload))
+		assert.Nil(t, err)
+
+		var gotMeta meta
+		errs := DecodeJSON(req, &gotMeta, Options{BufferBody: true})
+		assert.Nil(t, errs)
+
+		var gotForm form
+		errs = DecodeJSON(req, &gotForm)
+		assert.Nil(t, errs)
+
+		raw, ok := BufferedBody(req)
+		assert.True(t, ok)
+		assert.Nil(t, raw.Close())
+
+		assert.Equal(t, meta{ID: 1}, gotMeta)
+		assert.Equal(t, form{Username: "alice"}, gotForm)
+	})
+}
@@ -0,0 +1,206 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// charsetEncoding returns the encoding of the charset, or nil if the charset is
+// UTF-8 or not specified.
+func charsetEncoding(charset string) (encoding.Encoding, error) {
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	if charset == "" {
+		return nil, nil
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	if name, _ := htmlindex.Name(enc); name == "utf-8" {
+		return nil, nil
+	}
+	return enc, nil
+}
+
+// mediaCharset returns the charset parameter of the Content-Type of the request.
+func mediaCharset(r *http.Request) string {
+	_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return params["charset"]
+}
+
+// textBody returns the reader of the request body transcoded to UTF-8 according
+// to the charset of the Content-Type. The whole body is checked to be valid
+// UTF-8 when Options.RejectInvalidUTF8 is set, because decoders may silently
+// replace invalid bytes.
+func textBody(r *http.Request, opt Options) (io.Reader, *Error) {
+	var body io.Reader = r.Body
+	enc, err := charsetEncoding(mediaCharset(r))
+	if err != nil {
+		return nil, &Error{
+			Category: ErrorCategoryDeserialization,
+			Err:      err,
+		}
+	}
+	if enc != nil {
+		body = enc.NewDecoder().Reader(body)
+	}
+	if !opt.RejectInvalidUTF8 {
+		return body, nil
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		bodyErr := bodyError(r, err)
+		return nil, &bodyErr
+	}
+	if !utf8.Valid(data) {
+		return nil, &Error{
+			Category: ErrorCategoryDeserialization,
+			Err:      fmt.Errorf("request body contains invalid UTF-8"),
+		}
+	}
+	return bytes.NewReader(data), nil
+}
+
+// charsetField is the name of the form field in which browsers send the
+// charset of the form data.
+const charsetField = "_charset_"
+
+// transcodeForm returns a copy of the form with keys and values transcoded to
+// UTF-8 according to the charset of the Content-Type, or the "_charset_" field
+// sent by browsers when the Content-Type does not specify one. The form is
+// returned as-is when it is already UTF-8.
+func transcodeForm(r *http.Request, form url.Values) (url.Values, *Error) {
+	charset := mediaCharset(r)
+	if charset == "" {
+		charset = form.Get(charsetField)
+	}
+	enc, err := charsetEncoding(charset)
+	if err != nil {
+		return form, &Error{
+			Category: ErrorCategoryDeserialization,
+			Err:      err,
+		}
+	}
+	if enc == nil {
+		return form, nil
+	}
+
+	dec := enc.NewDecoder()
+	transcoded := make(url.Values, len(form))
+	for key, values := range form {
+		key, err = dec.String(key)
+		if err != nil {
+			return form, &Error{
+				Category: ErrorCategoryDeserialization,
+				Err:      fmt.Errorf("transcode from charset %q: %v", charset, err),
+			}
+		}
+		for _, v := range values {
+			v, err = dec.String(v)
+			if err != nil {
+				return form, &Error{
+					Category: ErrorCategoryDeserialization,
+					Err:      fmt.Errorf("transcode from charset %q: %v", charset, err),
+				}
+			}
+			transcoded[key] = append(transcoded[key], v)
+		}
+	}
+	return transcoded, nil
+}
+
+// checkText returns an error if the value of the field is invalid UTF-8 or
+// contains NUL bytes.
+func checkText(name, val string) *Error {
+	if !utf8.ValidString(val) {
+		return &Error{
+			Category: ErrorCategoryDeserialization,
+			Err:      fmt.Errorf("field %q contains invalid UTF-8", name),
+		}
+	}
+	if strings.IndexByte(val, 0) >= 0 {
+		return &Error{
+			Category: ErrorCategoryDeserialization,
+			Err:      fmt.Errorf("field %q contains NUL byte", name),
+		}
+	}
+	return nil
+}
+
+// checkStrings walks through the value decoded from the request body and checks
+// all strings in it with checkText. Field names are taken from the given struct
+// tag and joined by dots.
+func checkStrings(v reflect.Value, name, tag string, errs Errors) Errors {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			errs = checkStrings(v.Elem(), name, tag, errs)
+		}
+
+	case reflect.String:
+		if err := checkText(name, v.String()); err != nil {
+			errs = append(errs, *err)
+		}
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			fieldName, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+			if fieldName == "-" {
+				continue
+			} else if fieldName == "" && field.Anonymous {
+				// Fields of embedded structs are promoted.
+				errs = checkStrings(v.Field(i), name, tag, errs)
+				continue
+			} else if fieldName == "" {
+				fieldName = field.Name
+			}
+			if name != "" {
+				fieldName = name + "." + fieldName
+			}
+			errs = checkStrings(v.Field(i), fieldName, tag, errs)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			errs = checkStrings(v.Index(i), name+"["+strconv.Itoa(i)+"]", tag, errs)
+		}
+
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			keyName := fmt.Sprint(key.Interface())
+			if key.Kind() == reflect.String {
+				if err := checkText(name, keyName); err != nil {
+					errs = append(errs, *err)
+				}
+			}
+			errs = checkStrings(v.MapIndex(key), name+"["+keyName+"]", tag, errs)
+		}
+	}
+	return errs
+}
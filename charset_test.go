@@ -0,0 +1,171 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+func TestCharset(t *testing.T) {
+	type address struct {
+		City string `json:"city"`
+	}
+	type form struct {
+		Name    string   `json:"name" form:"name"`
+		Tags    []string `json:"tags" form:"tags"`
+		Address address  `json:"address"`
+	}
+
+	tests := []struct {
+		name        string
+		handler     flamego.Handler
+		url         string
+		contentType string
+		body        string
+		wantForm    form
+		wantErrs    Errors
+	}{
+		{
+			name:        "form with charset",
+			handler:     Form(form{}),
+			contentType: "application/x-www-form-urlencoded; charset=ISO-8859-1",
+			body:        "name=caf%E9",
+			wantForm:    form{Name: "café"},
+		},
+		{
+			name:        "form with charset and query",
+			handler:     Form(form{}),
+			url:         "/?tags=caf%C3%A9",
+			contentType: "application/x-www-form-urlencoded; charset=ISO-8859-1",
+			body:        "name=caf%E9&tags=th%E9",
+			wantForm:    form{Name: "café", Tags: []string{"thé", "café"}},
+		},
+		{
+			name:        "form with _charset_",
+			handler:     Form(form{}),
+			contentType: "application/x-www-form-urlencoded",
+			body:        "_charset_=Shift_JIS&name=%93%FA%96%7B",
+			wantForm:    form{Name: "日本"},
+		},
+		{
+			name:        "strict form with _charset_",
+			handler:     Form(form{}, Options{Strict: true}),
+			contentType: "application/x-www-form-urlencoded",
+			body:        "_charset_=Shift_JIS&name=%93%FA%96%7B",
+			wantForm:    form{Name: "日本"},
+		},
+		{
+			name:        "json with charset",
+			handler:     JSON(form{}),
+			contentType: "application/json; charset=Shift_JIS",
+			body:        "{\"name\": \"\x93\xfa\x96\x7b\"}",
+			wantForm:    form{Name: "日本"},
+		},
+		{
+			name:        "unsupported charset",
+			handler:     JSON(form{}),
+			contentType: "application/json; charset=klingon",
+			body:        `{"name": "alice"}`,
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceJSON,
+					Err:      errors.New(`unsupported charset "klingon"`),
+				},
+			},
+		},
+		{
+			name:        "invalid UTF-8 accepted by default",
+			handler:     Form(form{}),
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=%FF",
+			wantForm:    form{Name: "\xff"},
+		},
+		{
+			name:        "invalid UTF-8 in form",
+			handler:     Form(form{}, Options{RejectInvalidUTF8: true}),
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=%FF&tags=a&tags=b%00",
+			wantForm:    form{Tags: []string{"a", ""}},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceForm,
+					Err:      errors.New(`field "name" contains invalid UTF-8`),
+				},
+				{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceForm,
+					Err:      errors.New(`field "tags" contains NUL byte`),
+				},
+			},
+		},
+		{
+			name:        "NUL byte in JSON",
+			handler:     JSON(form{}, Options{RejectInvalidUTF8: true}),
+			contentType: "application/json",
+			body:        `{"name": "alice", "tags": ["a", "b\u0000"], "address": {"city": "\u0000"}}`,
+			wantForm:    form{Name: "alice", Tags: []string{"a", "b\x00"}, Address: address{City: "\x00"}},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceJSON,
+					Err:      errors.New(`field "tags[1]" contains NUL byte`),
+				},
+				{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceJSON,
+					Err:      errors.New(`field "address.city" contains NUL byte`),
+				},
+			},
+		},
+		{
+			name:        "invalid UTF-8 in JSON",
+			handler:     JSON(form{}, Options{RejectInvalidUTF8: true}),
+			contentType: "application/json",
+			body:        "{\"name\": \"\xff\"}",
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceJSON,
+					Err:      errors.New("request body contains invalid UTF-8"),
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotForm form
+			var gotErrs Errors
+			f := flamego.New()
+			f.Post("/", test.handler, func(form form, errs Errors) {
+				gotForm = form
+				gotErrs = errs
+			})
+
+			url := test.url
+			if url == "" {
+				url = "/"
+			}
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(test.body))
+			assert.Nil(t, err)
+			req.Header.Set("Content-Type", test.contentType)
+
+			f.ServeHTTP(resp, req)
+
+			assert.Equal(t, test.wantForm, gotForm)
+			assert.Equal(t, test.wantErrs, gotErrs)
+		})
+	}
+}
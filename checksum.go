@@ -0,0 +1,191 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// checksumHashes contains supported hash algorithms of checksums.
+var checksumHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// digestAlgorithms maps digest algorithms of the Digest header, see RFC 3230,
+// to supported hash algorithms.
+var digestAlgorithms = map[string]string{
+	"md5":     "md5",
+	"sha":     "sha1",
+	"sha-256": "sha256",
+	"sha-512": "sha512",
+}
+
+// declaredChecksums returns checksums declared by the Content-MD5 and Digest
+// headers of the file part, keyed by their hash algorithms. A malformed
+// checksum is kept as nil so that it never matches.
+func declaredChecksums(h textproto.MIMEHeader) map[string][]byte {
+	declared := make(map[string][]byte)
+	if v := h.Get("Content-MD5"); v != "" {
+		sum, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+		declared["md5"] = sum
+	}
+	for _, v := range h.Values("Digest") {
+		for _, digest := range strings.Split(v, ",") {
+			alg, value, ok := strings.Cut(strings.TrimSpace(digest), "=")
+			if !ok {
+				continue
+			}
+			name, ok := digestAlgorithms[strings.ToLower(alg)]
+			if !ok {
+				continue // Unsupported algorithms are ignored
+			}
+			sum, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+			declared[name] = sum
+		}
+	}
+	return declared
+}
+
+// checksummer computes checksums of the content written to it.
+type checksummer struct {
+	hashes map[string]hash.Hash
+	w      io.Writer
+}
+
+// newChecksummer returns a new checksummer of the given hash algorithms and
+// algorithms of declared checksums.
+func newChecksummer(algorithms []string, declared map[string][]byte) *checksummer {
+	c := &checksummer{hashes: make(map[string]hash.Hash)}
+	writers := make([]io.Writer, 0, len(algorithms)+len(declared))
+	add := func(name string) {
+		if _, ok := c.hashes[name]; ok {
+			return
+		}
+		h := checksumHashes[name]()
+		c.hashes[name] = h
+		writers = append(writers, h)
+	}
+	for _, name := range algorithms {
+		add(name)
+	}
+	for name := range declared {
+		add(name)
+	}
+	c.w = io.MultiWriter(writers...)
+	return c
+}
+
+func (c *checksummer) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+// sums returns the hex-encoded checksums keyed by their hash algorithms.
+func (c *checksummer) sums() map[string]string {
+	sums := make(map[string]string, len(c.hashes))
+	for name, h := range c.hashes {
+		sums[name] = hex.EncodeToString(h.Sum(nil))
+	}
+	return sums
+}
+
+// digest returns the value of the Digest header for the checksums.
+func (c *checksummer) digest() string {
+	digests := make([]string, 0, len(c.hashes))
+	for alg, name := range digestAlgorithms {
+		if h, ok := c.hashes[name]; ok {
+			digests = append(digests, strings.ToUpper(alg)+"="+base64.StdEncoding.EncodeToString(h.Sum(nil)))
+		}
+	}
+	sort.Strings(digests)
+	return strings.Join(digests, ",")
+}
+
+// verify returns an error if any declared checksum does not match.
+func (c *checksummer) verify(name, filename string, declared map[string][]byte) *Error {
+	algorithms := make([]string, 0, len(declared))
+	for alg := range declared {
+		algorithms = append(algorithms, alg)
+	}
+	sort.Strings(algorithms)
+
+	for _, alg := range algorithms {
+		if !bytes.Equal(c.hashes[alg].Sum(nil), declared[alg]) {
+			return &Error{
+				Category: ErrorCategoryDeserialization,
+				Err:      fmt.Errorf("file field %q: file %q does not match the declared %s checksum", name, filename, alg),
+			}
+		}
+	}
+	return nil
+}
+
+// checksumFileHeader computes checksums of the uploaded file and verifies them
+// against the declared ones. The computed checksums are recorded as the Digest
+// header of the file.
+func checksumFileHeader(name string, fh *multipart.FileHeader, opt Options) *Error {
+	declared := declaredChecksums(fh.Header)
+	if len(opt.Checksums) == 0 && len(declared) == 0 {
+		return nil
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return &Error{
+			Category: ErrorCategoryDeserialization,
+			Err:      fmt.Errorf("file field %q: %v", name, err),
+		}
+	}
+	defer func() { _ = f.Close() }()
+
+	c := newChecksummer(opt.Checksums, declared)
+	_, err = io.Copy(c, f)
+	if err != nil {
+		return &Error{
+			Category: ErrorCategoryDeserialization,
+			Err:      fmt.Errorf("file field %q: %v", name, err),
+		}
+	}
+	if err := c.verify(name, fh.Filename, declared); err != nil {
+		return err
+	}
+	fh.Header.Set("Digest", c.digest())
+	return nil
+}
+
+// checksumReader computes checksums of the content of the file part while it
+// is read, and records them to the file part once the content is read to the
+// end.
+type checksumReader struct {
+	r    io.Reader
+	c    *checksummer
+	part *FilePart
+	done bool // Whether the content is read to the end
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	_, _ = r.c.Write(p[:n])
+	if err == io.EOF && !r.done {
+		r.done = true
+		r.part.Checksums = r.c.sums()
+	}
+	return n, err
+}
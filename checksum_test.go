@@ -0,0 +1,176 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+func TestChecksums(t *testing.T) {
+	const content = "document content"
+	md5Sum := md5.Sum([]byte(content))
+	sha256Sum := sha256.Sum256([]byte(content))
+	wrongSum := sha256.Sum256([]byte("tampered content"))
+
+	tests := []struct {
+		name          string
+		opts          Options
+		header        map[string]string
+		wantChecksums map[string]string
+		wantDigest    string
+		wantErrs      Errors
+	}{
+		{
+			name:          "default",
+			wantChecksums: map[string]string{"sha256": hex.EncodeToString(sha256Sum[:])},
+			wantDigest:    "SHA-256=" + base64.StdEncoding.EncodeToString(sha256Sum[:]),
+		},
+		{
+			name: "algorithms",
+			opts: Options{Checksums: []string{"md5", "sha256"}},
+			wantChecksums: map[string]string{
+				"md5":    hex.EncodeToString(md5Sum[:]),
+				"sha256": hex.EncodeToString(sha256Sum[:]),
+			},
+			wantDigest: "MD5=" + base64.StdEncoding.EncodeToString(md5Sum[:]) +
+				",SHA-256=" + base64.StdEncoding.EncodeToString(sha256Sum[:]),
+		},
+		{
+			name:   "declared",
+			header: map[string]string{"Content-MD5": base64.StdEncoding.EncodeToString(md5Sum[:])},
+			wantChecksums: map[string]string{
+				"md5":    hex.EncodeToString(md5Sum[:]),
+				"sha256": hex.EncodeToString(sha256Sum[:]),
+			},
+			wantDigest: "MD5=" + base64.StdEncoding.EncodeToString(md5Sum[:]) +
+				",SHA-256=" + base64.StdEncoding.EncodeToString(sha256Sum[:]),
+		},
+		{
+			name:   "mismatch",
+			header: map[string]string{"Digest": "unknown=abc, sha-256=" + base64.StdEncoding.EncodeToString(wrongSum[:])},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "document": file "doc.txt" does not match the declared sha256 checksum`),
+				},
+			},
+		},
+		{
+			name:   "malformed",
+			header: map[string]string{"Content-MD5": "not base64"},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "document": file "doc.txt" does not match the declared md5 checksum`),
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newRequest := func(t *testing.T) *http.Request {
+				return newMultipartRequest(t, testPart{field: "document", filename: "doc.txt", content: content, header: test.header})
+			}
+
+			t.Run("staged", func(t *testing.T) {
+				type form struct {
+					Document *multipart.FileHeader `form:"document"`
+				}
+
+				var gotForm form
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}, test.opts), func(form form, errs Errors) {
+					gotForm = form
+					gotErrs = errs
+				})
+
+				f.ServeHTTP(httptest.NewRecorder(), newRequest(t))
+				assert.Equal(t, test.wantErrs, gotErrs)
+				if test.wantErrs != nil {
+					assert.Nil(t, gotForm.Document)
+					return
+				}
+				assert.Equal(t, test.wantDigest, gotForm.Document.Header.Get("Digest"))
+			})
+
+			t.Run("sink", func(t *testing.T) {
+				type form struct{}
+
+				var gotChecksums map[string]string
+				opts := test.opts
+				opts.StreamMultipart = true
+				opts.FileSinks = map[string]FileSink{
+					"document": func(part *FilePart) error {
+						// Read partially to make sure the rest is verified as well.
+						_, err := io.ReadFull(part, make([]byte, 4))
+						gotChecksums = part.Checksums
+						return err
+					},
+				}
+
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}, opts), func(errs Errors) {
+					gotErrs = errs
+				})
+
+				f.ServeHTTP(httptest.NewRecorder(), newRequest(t))
+				assert.Equal(t, test.wantErrs, gotErrs)
+				assert.Nil(t, gotChecksums)
+			})
+
+			t.Run("storage", func(t *testing.T) {
+				type form struct {
+					Document *StoredFile `form:"document"`
+				}
+
+				storage := NewMemoryStorage()
+				opts := test.opts
+				opts.Storage = storage
+
+				var gotForm form
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}, opts), func(form form, errs Errors) {
+					gotForm = form
+					gotErrs = errs
+				})
+
+				f.ServeHTTP(httptest.NewRecorder(), newRequest(t))
+				assert.Equal(t, test.wantErrs, gotErrs)
+				if test.wantErrs != nil {
+					assert.Nil(t, gotForm.Document)
+					assert.Empty(t, storage.files)
+					return
+				}
+				assert.Equal(t, test.wantChecksums, gotForm.Document.Checksums)
+			})
+		})
+	}
+
+	t.Run("unsupported algorithm", func(t *testing.T) {
+		type form struct{}
+		assert.PanicsWithValue(t,
+			`binding: unsupported checksum algorithm "crc32"`,
+			func() { MultipartForm(form{}, Options{Checksums: []string{"crc32"}}) },
+		)
+	})
+}
@@ -0,0 +1,199 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Decompressor returns a reader that decompresses the content of the given
+// reader.
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+// builtinDecompressors contains decompressors that are always available.
+var builtinDecompressors = map[string]Decompressor{
+	"gzip": func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	"x-gzip": func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	"deflate": func(r io.Reader) (io.ReadCloser, error) {
+		return zlib.NewReader(r)
+	},
+}
+
+// ratioCheckThreshold is the decompressed size after which the decompression
+// ratio starts to be checked, so that small payloads with naturally high ratios
+// are not rejected.
+const ratioCheckThreshold = 1 << 20 // 1 MiB
+
+// contentEncodings returns the list of content codings of the request body in
+// the order they are applied, excluding "identity".
+func contentEncodings(r *http.Request) []string {
+	var encodings []string
+	for _, v := range r.Header.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(v, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding != "" && encoding != "identity" {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+	return encodings
+}
+
+// decompressor returns the decompressor for the content coding, which is
+// looked up from the options before the built-in ones.
+func decompressor(encoding string, opt Options) Decompressor {
+	if d, ok := opt.Decompressors[encoding]; ok {
+		return d
+	}
+	return builtinDecompressors[encoding]
+}
+
+// countingReader counts the number of bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// decompressBody is the request body that decompresses the content of the
+// underlying body on the fly, and enforces limits of the decompressed size and
+// the decompression ratio.
+type decompressBody struct {
+	src       io.ReadCloser // The underlying body
+	encodings []string      // The list of content codings in the order they are applied
+	opt       Options
+
+	compressed *countingReader
+	readers    []io.ReadCloser // The chain of decompressors, initialized on the first read
+	n          int64           // The number of decompressed bytes
+	err        error           // The first error occurred during decompression
+}
+
+func (b *decompressBody) init() error {
+	b.compressed = &countingReader{r: b.src}
+	var r io.Reader = b.compressed
+	for i := len(b.encodings) - 1; i >= 0; i-- {
+		rc, err := decompressor(b.encodings[i], b.opt)(r)
+		if err != nil {
+			return fmt.Errorf("decompress %q: %v", b.encodings[i], err)
+		}
+		b.readers = append(b.readers, rc)
+		r = rc
+	}
+	return nil
+}
+
+func (b *decompressBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.readers == nil {
+		b.err = b.init()
+		if b.err != nil {
+			return 0, b.err
+		}
+	}
+
+	n, err := b.readers[len(b.readers)-1].Read(p)
+	b.n += int64(n)
+	if b.n > b.opt.MaxDecompressedSize {
+		b.err = fmt.Errorf("decompressed body exceeds the limit %d bytes", b.opt.MaxDecompressedSize)
+		return 0, b.err
+	}
+	if b.n > ratioCheckThreshold && b.compressed.n > 0 && b.n/b.compressed.n > int64(b.opt.MaxDecompressionRatio) {
+		b.err = fmt.Errorf("decompression ratio exceeds the limit %d", b.opt.MaxDecompressionRatio)
+		return 0, b.err
+	}
+	if err != nil && err != io.EOF {
+		b.err = fmt.Errorf("decompress: %v", err)
+		return n, b.err
+	}
+	return n, err
+}
+
+func (b *decompressBody) Close() error {
+	for _, rc := range b.readers {
+		_ = rc.Close()
+	}
+	return b.src.Close()
+}
+
+// decompressRequestBody replaces the request body with one that decompresses
+// its content according to the Content-Encoding of the request. Headers that
+// describe the compressed content are removed, so that the request body is
+// never decompressed twice.
+func decompressRequestBody(r *http.Request, opt Options) *Error {
+	encodings := contentEncodings(r)
+	if len(encodings) == 0 {
+		return nil
+	}
+
+	for _, encoding := range encodings {
+		if decompressor(encoding, opt) == nil {
+			return &Error{
+				Category: ErrorCategoryDecompression,
+				Err:      fmt.Errorf("unsupported content encoding %q", encoding),
+			}
+		}
+	}
+	r.Body = &decompressBody{
+		src:       r.Body,
+		encodings: encodings,
+		opt:       opt,
+	}
+	r.ContentLength = -1
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	return nil
+}
+
+// bodyError returns the error occurred when decoding the request body. It is
+// categorized by the innermost cause along the wrapped request body, i.e.
+// timeout, request too large or decompression error.
+func bodyError(r *http.Request, err error) Error {
+	bodyErr := Error{
+		Category: ErrorCategoryDeserialization,
+		Err:      err,
+	}
+	body := r.Body
+	for body != nil {
+		switch b := body.(type) {
+		case *decompressBody:
+			if b.err != nil {
+				bodyErr = Error{Category: ErrorCategoryDecompression, Err: b.err}
+			}
+			body = b.src
+		case *limitedBody:
+			if b.exceeded {
+				bodyErr = Error{Category: ErrorCategoryRequestTooLarge, Err: b.err()}
+			}
+			body = b.src
+		case *timeoutBody:
+			if b.timedOut {
+				bodyErr = Error{Category: ErrorCategoryTimeout, Err: b.err}
+			} else if b.err != nil {
+				bodyErr = Error{Category: ErrorCategoryDeserialization, Err: b.err}
+			}
+			body = nil
+		default:
+			body = nil
+		}
+	}
+	return bodyErr
+}
@@ -0,0 +1,203 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(s))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func deflated(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	_, err := w.Write([]byte(s))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestDecompression(t *testing.T) {
+	type form struct {
+		Username string `json:"username" form:"username"`
+	}
+	const payload = `{"username": "alice"}`
+
+	tests := []struct {
+		name        string
+		handler     flamego.Handler
+		encoding    string
+		contentType string
+		body        []byte
+		wantForm    form
+		wantErrs    Errors
+	}{
+		{
+			name:     "gzip",
+			handler:  JSON(form{}),
+			encoding: "gzip",
+			body:     gzipped(t, payload),
+			wantForm: form{Username: "alice"},
+		},
+		{
+			name:     "deflate",
+			handler:  JSON(form{}),
+			encoding: "deflate",
+			body:     deflated(t, payload),
+			wantForm: form{Username: "alice"},
+		},
+		{
+			name:     "stacked",
+			handler:  JSON(form{}),
+			encoding: "deflate, gzip",
+			body:     gzipped(t, string(deflated(t, payload))),
+			wantForm: form{Username: "alice"},
+		},
+		{
+			name:     "identity",
+			handler:  JSON(form{}),
+			encoding: "identity",
+			body:     []byte(payload),
+			wantForm: form{Username: "alice"},
+		},
+		{
+			name:        "form",
+			handler:     Form(form{}),
+			encoding:    "gzip",
+			contentType: "application/x-www-form-urlencoded",
+			body:        gzipped(t, "username=alice"),
+			wantForm:    form{Username: "alice"},
+		},
+		{
+			name:     "custom decompressor",
+			handler:  JSON(form{}, Options{Decompressors: map[string]Decompressor{"rot": func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(r), nil }}}),
+			encoding: "rot",
+			body:     []byte(payload),
+			wantForm: form{Username: "alice"},
+		},
+		{
+			name:     "unsupported encoding",
+			handler:  JSON(form{}),
+			encoding: "br",
+			body:     []byte(payload),
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDecompression,
+					Source:   SourceJSON,
+					Err:      errors.New(`unsupported content encoding "br"`),
+				},
+			},
+		},
+		{
+			name:     "corrupted",
+			handler:  JSON(form{}),
+			encoding: "gzip",
+			body:     []byte(payload),
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDecompression,
+					Source:   SourceJSON,
+					Err:      errors.New(`decompress "gzip": gzip: invalid header`),
+				},
+			},
+		},
+		{
+			name:     "size limit",
+			handler:  JSON(form{}, Options{MaxDecompressedSize: 8}),
+			encoding: "gzip",
+			body:     gzipped(t, payload),
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDecompression,
+					Source:   SourceJSON,
+					Err:      errors.New("decompressed body exceeds the limit 8 bytes"),
+				},
+			},
+		},
+		{
+			name:     "ratio limit",
+			handler:  JSON(form{}),
+			encoding: "gzip",
+			body:     gzipped(t, `{"username": "`+strings.Repeat("a", 4<<20)+`"}`),
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDecompression,
+					Source:   SourceJSON,
+					Err:      errors.New("decompression ratio exceeds the limit 100"),
+				},
+			},
+		},
+		{
+			name:     "buffered",
+			handler:  JSON(form{}, Options{BufferBody: true, MaxDecompressedSize: 8}),
+			encoding: "gzip",
+			body:     gzipped(t, payload),
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDecompression,
+					Source:   SourceJSON,
+					Err:      errors.New("decompressed body exceeds the limit 8 bytes"),
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotForm form
+			var gotErrs Errors
+			f := flamego.New()
+			f.Post("/", test.handler, func(form form, errs Errors) {
+				gotForm = form
+				gotErrs = errs
+			})
+
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, "/", bytes.NewReader(test.body))
+			assert.Nil(t, err)
+			req.Header.Set("Content-Encoding", test.encoding)
+			req.Header.Set("Content-Type", test.contentType)
+
+			f.ServeHTTP(resp, req)
+
+			assert.Equal(t, test.wantForm, gotForm)
+			assert.Equal(t, test.wantErrs, gotErrs)
+		})
+	}
+
+	t.Run("replayed", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/", bytes.NewReader(gzipped(t, payload)))
+		assert.Nil(t, err)
+		req.Header.Set("Content-Encoding", "gzip")
+
+		var first, second form
+		assert.Nil(t, DecodeJSON(req, &first, Options{BufferBody: true}))
+		assert.Nil(t, DecodeJSON(req, &second))
+		assert.Equal(t, form{Username: "alice"}, first)
+		assert.Equal(t, form{Username: "alice"}, second)
+
+		raw, ok := BufferedBody(req)
+		assert.True(t, ok)
+		assert.Nil(t, raw.Close())
+	})
+}
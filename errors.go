@@ -8,8 +8,26 @@ package binding
 type ErrorCategory string
 
 const (
-	ErrorCategoryDeserialization ErrorCategory = "deserialization"
-	ErrorCategoryValidation      ErrorCategory = "validation"
+	ErrorCategoryDeserialization      ErrorCategory = "deserialization"
+	ErrorCategoryValidation           ErrorCategory = "validation"
+	ErrorCategoryDecompression        ErrorCategory = "decompression"
+	ErrorCategoryUnsupportedMediaType ErrorCategory = "unsupported-media-type"
+	ErrorCategoryRequestTooLarge      ErrorCategory = "request-too-large"
+	ErrorCategoryTimeout              ErrorCategory = "timeout"
+)
+
+// Source represents the part of the request that an error originates from.
+type Source string
+
+const (
+	SourceJSON          Source = "json"
+	SourceYAML          Source = "yaml"
+	SourceForm          Source = "form"
+	SourceMultipartForm Source = "multipart-form"
+	SourceQuery         Source = "query"
+	SourceParams        Source = "params"
+	SourceHeader        Source = "header"
+	SourceCookie        Source = "cookie"
 )
 
 type (
@@ -24,5 +42,8 @@ type (
 		Category ErrorCategory `json:"category,omitempty"`
 		// Err is the underlying error.
 		Err error `json:"error,omitempty"`
+		// Source is the part of the request that the error originates from, it is
+		// empty when the error does not belong to any single part.
+		Source Source `json:"source,omitempty"`
 	}
 )
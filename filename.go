@@ -0,0 +1,101 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"fmt"
+	"mime"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxFilenameLen is the maximum length in bytes of sanitized filenames, which
+// is the limit of most file systems.
+const maxFilenameLen = 255
+
+// reservedFilenames contains device names reserved by Windows, which are
+// reserved regardless of the extension.
+var reservedFilenames = map[string]struct{}{
+	"CON": {}, "PRN": {}, "AUX": {}, "NUL": {},
+	"COM1": {}, "COM2": {}, "COM3": {}, "COM4": {}, "COM5": {}, "COM6": {}, "COM7": {}, "COM8": {}, "COM9": {},
+	"LPT1": {}, "LPT2": {}, "LPT3": {}, "LPT4": {}, "LPT5": {}, "LPT6": {}, "LPT7": {}, "LPT8": {}, "LPT9": {},
+}
+
+// sanitizeFilename returns the filename given by the client in a form that is
+// safe to be used as a single path element. It normalizes the filename to
+// Unicode NFC, strips directories, control and formatting characters, replaces
+// characters reserved by common file systems, trims leading and trailing dots
+// and spaces, prefixes reserved device names, and truncates it to 255 bytes
+// while keeping the extension. An empty string is returned if nothing is left.
+func sanitizeFilename(filename string) string {
+	filename = norm.NFC.String(strings.ToValidUTF8(filename, ""))
+	if i := strings.LastIndexAny(filename, `/\`); i >= 0 {
+		filename = filename[i+1:]
+	}
+
+	filename = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			return -1
+		case strings.ContainsRune(`<>:"|?*`, r):
+			return '_'
+		}
+		return r
+	}, filename)
+	filename = strings.Trim(filename, ". ")
+	if filename == "" {
+		return ""
+	}
+
+	base := filename
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	if _, ok := reservedFilenames[strings.ToUpper(strings.TrimSpace(base))]; ok {
+		filename = "_" + filename
+	}
+
+	if len(filename) > maxFilenameLen {
+		ext := filepath.Ext(filename)
+		if len(ext) > maxFilenameLen/2 {
+			ext = ""
+		}
+		name := filename[:maxFilenameLen-len(ext)]
+		for len(name) > 0 && !utf8.ValidString(name) {
+			name = name[:len(name)-1]
+		}
+		filename = name + ext
+	}
+	return filename
+}
+
+// rawFilename returns the filename in the Content-Disposition of the header as
+// is, since the one given by mime/multipart has directories stripped already.
+func rawFilename(h textproto.MIMEHeader, filename string) string {
+	_, params, err := mime.ParseMediaType(h.Get("Content-Disposition"))
+	if err == nil && params["filename"] != "" {
+		return params["filename"]
+	}
+	return filename
+}
+
+// checkFilename returns the sanitized filename of the uploaded file, or an
+// error if the filename is changed by sanitization other than normalization
+// when Options.RejectUnsafeFilenames is set.
+func checkFilename(name, filename string, opt Options) (string, *Error) {
+	sanitized := sanitizeFilename(filename)
+	if opt.RejectUnsafeFilenames && (sanitized == "" || sanitized != norm.NFC.String(filename)) {
+		return "", &Error{
+			Category: ErrorCategoryValidation,
+			Err:      fmt.Errorf("file field %q: file %q has an unsafe filename", name, filename),
+		}
+	}
+	return sanitized, nil
+}
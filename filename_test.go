@@ -0,0 +1,139 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		want     string
+	}{
+		{name: "plain", filename: "report.pdf", want: "report.pdf"},
+		{name: "unix traversal", filename: "../../etc/passwd", want: "passwd"},
+		{name: "windows path", filename: `C:\Users\me\photo.jpg`, want: "photo.jpg"},
+		{name: "control characters", filename: "re\x00port\r\n.pdf", want: "report.pdf"},
+		{name: "bidi override", filename: "invoice\u202Efdp.exe", want: "invoicefdp.exe"},
+		{name: "reserved characters", filename: `what?<is>"this"*.txt`, want: "what__is__this__.txt"},
+		{name: "dots and spaces", filename: " ..hidden. ", want: "hidden"},
+		{name: "only dots", filename: "..", want: ""},
+		{name: "device name", filename: "con", want: "_con"},
+		{name: "device name with extension", filename: "LPT1.txt", want: "_LPT1.txt"},
+		{name: "not device name", filename: "console.txt", want: "console.txt"},
+		{name: "normalized", filename: "cafe\u0301.txt", want: "caf\u00e9.txt"},
+		{name: "invalid UTF-8", filename: "bad\xffname.txt", want: "badname.txt"},
+		{
+			name:     "too long",
+			filename: strings.Repeat("長", 100) + ".txt",
+			want:     strings.Repeat("長", 83) + ".txt",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := sanitizeFilename(test.filename)
+			assert.Equal(t, test.want, got)
+			assert.LessOrEqual(t, len(got), maxFilenameLen)
+		})
+	}
+}
+
+func TestUnsafeFilenames(t *testing.T) {
+	newRequest := func(t *testing.T, filename string) *http.Request {
+		return newMultipartRequest(t, testPart{field: "document", filename: filename, content: "content"})
+	}
+
+	tests := []struct {
+		name         string
+		filename     string
+		opts         Options
+		wantFilename string
+		wantErrs     Errors
+	}{
+		{
+			name:         "safe",
+			filename:     "report.pdf",
+			opts:         Options{RejectUnsafeFilenames: true},
+			wantFilename: "report.pdf",
+		},
+		{
+			name:         "sanitized",
+			filename:     "../../con.txt",
+			wantFilename: "_con.txt",
+		},
+		{
+			name:     "rejected",
+			filename: "../../etc/passwd",
+			opts:     Options{RejectUnsafeFilenames: true},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryValidation,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "document": file "../../etc/passwd" has an unsafe filename`),
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Run("staged", func(t *testing.T) {
+				type form struct {
+					Document *multipart.FileHeader `form:"document"`
+				}
+
+				var gotForm form
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}, test.opts), func(form form, errs Errors) {
+					gotForm = form
+					gotErrs = errs
+				})
+
+				f.ServeHTTP(httptest.NewRecorder(), newRequest(t, test.filename))
+				assert.Equal(t, test.wantErrs, gotErrs)
+				if test.wantErrs != nil {
+					assert.Nil(t, gotForm.Document)
+					return
+				}
+				assert.Equal(t, test.wantFilename, gotForm.Document.Filename)
+			})
+
+			t.Run("streamed", func(t *testing.T) {
+				type form struct {
+					Document *StoredFile `form:"document"`
+				}
+
+				opts := test.opts
+				opts.Storage = NewMemoryStorage()
+				var gotForm form
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}, opts), func(form form, errs Errors) {
+					gotForm = form
+					gotErrs = errs
+				})
+
+				f.ServeHTTP(httptest.NewRecorder(), newRequest(t, test.filename))
+				assert.Equal(t, test.wantErrs, gotErrs)
+				if test.wantErrs != nil {
+					assert.Nil(t, gotForm.Document)
+					return
+				}
+				assert.Equal(t, test.wantFilename, gotForm.Document.Filename)
+			})
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"reflect"
+
+	"github.com/flamego/flamego"
+)
+
+// pointerModel is the model of a pointer type T, which lets binders populate the
+// type that T points to but map the pointer to the request context.
+type pointerModel[T any] struct{}
+
+func (pointerModel[T]) wrappedType() reflect.Type        { return typeOf[T]().Elem() }
+func (pointerModel[T]) wrap(v reflect.Value) interface{} { return v.Addr().Interface().(T) }
+
+// modelOf returns the model to be passed to binders for the type T. It panics if
+// T is neither a pointer nor a type that has a concrete zero value.
+func modelOf[T any]() interface{} {
+	typ := typeOf[T]()
+	switch typ.Kind() {
+	case reflect.Ptr:
+		if typ.Elem().Kind() == reflect.Ptr {
+			panic("binding: pointer to pointer can not be accepted as binding model")
+		}
+		return pointerModel[T]{}
+	case reflect.Interface:
+		panic("binding: interface can not be accepted as binding model")
+	}
+
+	var zero T
+	return zero
+}
+
+// JSONOf is the generic version of binding.JSON, T can be a pointer type and the
+// pointer is injected into the request context.
+func JSONOf[T any](opts ...Options) flamego.Handler {
+	return JSON(modelOf[T](), opts...)
+}
+
+// YAMLOf is the generic version of binding.YAML, T can be a pointer type and the
+// pointer is injected into the request context.
+func YAMLOf[T any](opts ...Options) flamego.Handler {
+	return YAML(modelOf[T](), opts...)
+}
+
+// FormOf is the generic version of binding.Form, T can be a pointer type and the
+// pointer is injected into the request context.
+func FormOf[T any](opts ...Options) flamego.Handler {
+	return Form(modelOf[T](), opts...)
+}
+
+// MultipartFormOf is the generic version of binding.MultipartForm, T can be a
+// pointer type and the pointer is injected into the request context.
+func MultipartFormOf[T any](opts ...Options) flamego.Handler {
+	return MultipartForm(modelOf[T](), opts...)
+}
+
+// QueryOf is the generic version of binding.Query, T can be a pointer type and
+// the pointer is injected into the request context.
+func QueryOf[T any](opts ...Options) flamego.Handler {
+	return Query(modelOf[T](), opts...)
+}
+
+// ParamsOf is the generic version of binding.Params, T can be a pointer type and
+// the pointer is injected into the request context.
+func ParamsOf[T any](opts ...Options) flamego.Handler {
+	return Params(modelOf[T](), opts...)
+}
+
+// HeaderOf is the generic version of binding.Header, T can be a pointer type and
+// the pointer is injected into the request context.
+func HeaderOf[T any](opts ...Options) flamego.Handler {
+	return Header(modelOf[T](), opts...)
+}
+
+// CookieOf is the generic version of binding.Cookie, T can be a pointer type and
+// the pointer is injected into the request context.
+func CookieOf[T any](opts ...Options) flamego.Handler {
+	return Cookie(modelOf[T](), opts...)
+}
+
+// RequestOf is the generic version of binding.Request, T can be a pointer type
+// and the pointer is injected into the request context.
+func RequestOf[T any](opts ...Options) flamego.Handler {
+	return Request(modelOf[T](), opts...)
+}
+
+// Handle returns a handler that calls the given function with the model
+// instance of type T and binding.Errors in the request context, which are
+// usually injected by a preceding binder of T. The zero value is used for
+// either one that is absent from the request context.
+func Handle[T any](fn func(c flamego.Context, v T, errs Errors)) flamego.Handler {
+	return flamego.ContextInvoker(func(c flamego.Context) {
+		var v T
+		if val := c.Value(typeOf[T]()); val.IsValid() {
+			v = val.Interface().(T)
+		}
+
+		var errs Errors
+		if val := c.Value(reflect.TypeOf(errs)); val.IsValid() {
+			errs = val.Interface().(Errors)
+		}
+		fn(c, v, errs)
+	})
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+func TestGeneric(t *testing.T) {
+	type form struct {
+		Username string `json:"username" form:"username" validate:"required"`
+	}
+
+	t.Run("value model", func(t *testing.T) {
+		var got form
+		var gotErrs Errors
+		f := flamego.New()
+		f.Post("/", JSONOf[form](), Handle(func(c flamego.Context, v form, errs Errors) {
+			got = v
+			gotErrs = errs
+		}))
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"username": "alice"}`))
+		assert.Nil(t, err)
+
+		f.ServeHTTP(resp, req)
+		assert.Nil(t, gotErrs)
+		assert.Equal(t, form{Username: "alice"}, got)
+	})
+
+	t.Run("pointer model", func(t *testing.T) {
+		var got *form
+		var gotErrs Errors
+		f := flamego.New()
+		f.Post("/", FormOf[*form](), Handle(func(c flamego.Context, v *form, errs Errors) {
+			got = v
+			gotErrs = errs
+		}))
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`username=`))
+		assert.Nil(t, err)
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		f.ServeHTTP(resp, req)
+		assert.Len(t, gotErrs, 1)
+		assert.Equal(t, ErrorCategoryValidation, gotErrs[0].Category)
+		assert.Equal(t, &form{}, got)
+	})
+
+	t.Run("invalid models", func(t *testing.T) {
+		assert.PanicsWithValue(t,
+			"binding: pointer to pointer can not be accepted as binding model",
+			func() {
+				JSONOf[**form]()
+			},
+		)
+		assert.PanicsWithValue(t,
+			"binding: interface can not be accepted as binding model",
+			func() {
+				JSONOf[interface{}]()
+			},
+		)
+	})
+
+	t.Run("absent model", func(t *testing.T) {
+		called := false
+		f := flamego.New()
+		f.Get("/", Handle(func(c flamego.Context, v *form, errs Errors) {
+			called = true
+			assert.Nil(t, v)
+			assert.Nil(t, errs)
+		}))
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		assert.Nil(t, err)
+
+		f.ServeHTTP(resp, req)
+		assert.True(t, called)
+	})
+}
@@ -0,0 +1,223 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// DecodeFunc decodes the request into v, which must be a non-nil pointer, and
+// returns any deserialization, binding, or validation errors.
+type DecodeFunc func(r *http.Request, v interface{}, opts ...Options) Errors
+
+// decode decodes the request into v using the given function, and then
+// validates it. For functions that read the request body, i.e. mediaTypes is
+// not nil, the Content-Type, the Content-Length and the header-bound fields are
+// checked before reading, and the request body is prepared to be replayable,
+// and the buffered body should be closed by the caller. Files saved to
+// Options.Storage are removed when any error is returned. Both
+// Options.ErrorHandler and Options.ResetErrors have no effect.
+func decode(
+	r *http.Request,
+	v interface{},
+	source Source,
+	mediaTypes []string,
+	opts []Options,
+	fn func(r *http.Request, obj reflect.Value, opt Options) Errors,
+) Errors {
+	obj := reflect.ValueOf(v)
+	if obj.Kind() != reflect.Ptr || obj.IsNil() {
+		panic("binding: non-nil pointer must be given to decode into")
+	}
+
+	var opt Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	opt = parseOptions(opt)
+	storage := trackStorage(opt.Storage)
+	if storage != nil {
+		opt.Storage = storage
+	}
+
+	var errs Errors
+	var fields []requestField
+	if mediaTypes != nil {
+		fields = headerFields(obj.Elem().Type(), nil, nil)
+		err := checkMediaType(r, opt, mediaTypes)
+		if err == nil {
+			errs = checkBeforeBody(r, obj, fields, opt)
+		}
+		if err == nil && len(errs) == 0 {
+			w, _ := r.Context().Value(responseWriterContextKey{}).(http.ResponseWriter)
+			_, err = prepareBody(r, w, opt)
+		}
+		if err != nil {
+			errs = append(errs, *err)
+		}
+	}
+
+	// The request body is unusable when it failed to be prepared.
+	if len(errs) == 0 {
+		errs = fn(r, obj, opt)
+		// Header-bound fields always take values of the header that have been
+		// checked, even if the request body carries the same names.
+		_ = mapHeaderValues(obj.Elem(), fields, r.Header, opt)
+	}
+	errs = validate(r.Context(), opt, source, obj, errs)
+	if len(errs) > 0 {
+		storage.removeAll()
+	}
+	return errs
+}
+
+// DecodeJSON populates v by deserializing the JSON payload from the request
+// body, and then validates it. It is the same as binding.JSON but works without
+// flamego.
+func DecodeJSON(r *http.Request, v interface{}, opts ...Options) Errors {
+	return decode(r, v, SourceJSON, jsonMediaTypes, opts, decodeJSON)
+}
+
+// DecodeYAML populates v by deserializing the YAML payload from the request
+// body, and then validates it. It is the same as binding.YAML but works without
+// flamego.
+func DecodeYAML(r *http.Request, v interface{}, opts ...Options) Errors {
+	return decode(r, v, SourceYAML, yamlMediaTypes, opts, decodeYAML)
+}
+
+// DecodeForm populates v by deserializing the payload from both form-urlencoded
+// data request body and URL query parameters, and then validates it. It is the
+// same as binding.Form but works without flamego.
+func DecodeForm(r *http.Request, v interface{}, opts ...Options) Errors {
+	return decode(r, v, SourceForm, formMediaTypes, opts, decodeForm)
+}
+
+// DecodeMultipartForm populates v by deserializing the multipart form from the
+// request body, and then validates it. It is the same as binding.MultipartForm
+// but works without flamego.
+func DecodeMultipartForm(r *http.Request, v interface{}, opts ...Options) Errors {
+	return decode(r, v, SourceMultipartForm, multipartFormMediaTypes, opts, decodeMultipartForm)
+}
+
+// DecodeQuery populates v by deserializing the payload from URL query
+// parameters, and then validates it. It is the same as binding.Query but works
+// without flamego.
+func DecodeQuery(r *http.Request, v interface{}, opts ...Options) Errors {
+	return decode(r, v, SourceQuery, nil, opts, decodeQuery)
+}
+
+// DecodeHeader populates v by deserializing the request headers, and then
+// validates it. It is the same as binding.Header but works without flamego.
+func DecodeHeader(r *http.Request, v interface{}, opts ...Options) Errors {
+	return decode(r, v, SourceHeader, nil, opts, func(r *http.Request, obj reflect.Value, opt Options) Errors {
+		return decodeValues(url.Values(r.Header), nil, obj, "header", false, opt, nil)
+	})
+}
+
+// DecodeCookie populates v by deserializing the request cookies, and then
+// validates it. It is the same as binding.Cookie but works without flamego.
+func DecodeCookie(r *http.Request, v interface{}, opts ...Options) Errors {
+	return decode(r, v, SourceCookie, nil, opts, func(r *http.Request, obj reflect.Value, opt Options) Errors {
+		return decodeValues(cookieValues(r), nil, obj, "cookie", false, opt, nil)
+	})
+}
+
+// DecodeParams populates v by deserializing the given bind parameters of the
+// route, which are usually extracted by the router in use, and then validates
+// it. It is the same as binding.Params but works without flamego.
+func DecodeParams(r *http.Request, params map[string]string, v interface{}, opts ...Options) Errors {
+	return decode(r, v, SourceParams, nil, opts, func(r *http.Request, obj reflect.Value, opt Options) Errors {
+		return decodeValues(paramValues(params), nil, obj, "param", true, opt, nil)
+	})
+}
+
+type (
+	// modelContextKey is the request context key for the model instance of the
+	// type.
+	modelContextKey struct {
+		typ reflect.Type
+	}
+	// errorsContextKey is the request context key for binding.Errors.
+	errorsContextKey struct{}
+)
+
+// Middleware returns a net/http middleware that decodes a new instance of the
+// model using the decode function, e.g. binding.DecodeJSON, and stores both the
+// model instance and binding.Errors in the request context for handlers to
+// retrieve via binding.ValueFromContext and binding.ErrorsFromContext. Errors
+// are appended to the ones stored by previous middleware unless
+// Options.ResetErrors is set, and Options.ErrorHandler has no effect. The
+// buffered request body and temporary files of the parsed multipart form, if
+// any, are released after the next handler returns, and so are files saved to
+// Options.Storage when there are errors or the next handler panics.
+func Middleware(decode DecodeFunc, model interface{}, opts ...Options) func(http.Handler) http.Handler {
+	ensureNotPointer(model)
+
+	var opt Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(context.WithValue(r.Context(), responseWriterContextKey{}, w))
+			opt := opt
+			storage := trackStorage(opt.Storage)
+			if storage != nil {
+				opt.Storage = storage
+			}
+
+			obj := reflect.New(modelType(model))
+			multipartForm := r.MultipartForm
+			errs := decode(r, obj.Interface(), opt)
+			if form := r.MultipartForm; form != nil && form != multipartForm && !opt.KeepMultipartFiles {
+				defer func() { _ = form.RemoveAll() }()
+			}
+			if raw, ok := BufferedBody(r); ok {
+				defer func() { _ = raw.Close() }()
+			}
+			if !opt.ResetErrors {
+				errs = appendErrors(ErrorsFromContext(r.Context()), errs)
+			}
+			finished := false
+			if storage.stored() {
+				defer func() {
+					if len(errs) > 0 || !finished {
+						storage.removeAll()
+					}
+				}()
+			}
+
+			mapped := mappedValue(model, obj)
+			ctx := context.WithValue(r.Context(), modelContextKey{typ: reflect.TypeOf(mapped)}, mapped)
+			ctx = context.WithValue(ctx, errorsContextKey{}, errs)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			finished = true
+		})
+	}
+}
+
+// MiddlewareOf is the generic version of binding.Middleware, T can be a pointer
+// type and the pointer is stored in the request context.
+func MiddlewareOf[T any](decode DecodeFunc, opts ...Options) func(http.Handler) http.Handler {
+	return Middleware(decode, modelOf[T](), opts...)
+}
+
+// ValueFromContext returns the model instance of type T stored in the request
+// context by binding.Middleware, and whether it is present.
+func ValueFromContext[T any](ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(modelContextKey{typ: typeOf[T]()}).(T)
+	return v, ok
+}
+
+// ErrorsFromContext returns binding.Errors stored in the request context by
+// binding.Middleware.
+func ErrorsFromContext(ctx context.Context) Errors {
+	errs, _ := ctx.Value(errorsContextKey{}).(Errors)
+	return errs
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecode(t *testing.T) {
+	type form struct {
+		Username string `json:"username" form:"username" validate:"required"`
+		Age      int    `json:"age" form:"age"`
+	}
+
+	t.Run("non-pointer", func(t *testing.T) {
+		assert.PanicsWithValue(t,
+			"binding: non-nil pointer must be given to decode into",
+			func() {
+				req, _ := http.NewRequest(http.MethodPost, "/", nil)
+				DecodeJSON(req, form{})
+			},
+		)
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"age": 20}`))
+		assert.Nil(t, err)
+
+		var got form
+		errs := DecodeJSON(req, &got)
+		assert.Len(t, errs, 1)
+		assert.Equal(t, ErrorCategoryValidation, errs[0].Category)
+		assert.Equal(t, SourceJSON, errs[0].Source)
+		assert.Equal(t, form{Age: 20}, got)
+	})
+
+	t.Run("form", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/?age=20", bytes.NewBufferString(`username=alice`))
+		assert.Nil(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		var got form
+		errs := DecodeForm(req, &got)
+		assert.Nil(t, errs)
+		assert.Equal(t, form{Username: "alice", Age: 20}, got)
+	})
+
+	t.Run("params", func(t *testing.T) {
+		type params struct {
+			ID int `param:"id"`
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "/issues/42", nil)
+		assert.Nil(t, err)
+
+		var got params
+		errs := DecodeParams(req, map[string]string{"id": "42"}, &got)
+		assert.Nil(t, errs)
+		assert.Equal(t, params{ID: 42}, got)
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	type filter struct {
+		Name string `query:"name" json:"name"`
+		Page int    `query:"page" json:"page" validate:"gte=1"`
+	}
+
+	var gotQuery FromQuery[filter]
+	var gotBody *filter
+	var gotErrs Errors
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ok bool
+		gotQuery, ok = ValueFromContext[FromQuery[filter]](r.Context())
+		assert.True(t, ok)
+		gotBody, ok = ValueFromContext[*filter](r.Context())
+		assert.True(t, ok)
+		_, ok = ValueFromContext[filter](r.Context())
+		assert.False(t, ok)
+		gotErrs = ErrorsFromContext(r.Context())
+	})
+
+	h := Middleware(DecodeQuery, FromQuery[filter]{})(MiddlewareOf[*filter](DecodeJSON)(handler))
+
+	resp := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodPost, "/?name=alice&page=bad", bytes.NewBufferString(`{"name": "bob", "page": 2}`))
+	assert.Nil(t, err)
+
+	h.ServeHTTP(resp, req)
+
+	assert.Equal(t, filter{Name: "alice"}, gotQuery.Value)
+	assert.Equal(t, &filter{Name: "bob", Page: 2}, gotBody)
+
+	var got []Error
+	for _, err := range gotErrs {
+		got = append(got, Error{Category: err.Category, Source: err.Source})
+	}
+	want := []Error{
+		{Category: ErrorCategoryDeserialization, Source: SourceQuery},
+		{Category: ErrorCategoryValidation, Source: SourceQuery},
+	}
+	assert.Equal(t, want, got)
+}
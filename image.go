@@ -0,0 +1,131 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"  // Register the GIF format
+	_ "image/jpeg" // Register the JPEG format
+	_ "image/png"  // Register the PNG format
+	"io"
+	"mime/multipart"
+	"strconv"
+	"strings"
+)
+
+// imageTag is the restrictions of uploaded images for a form field, which is
+// given by the "image" struct tag in the form of
+// `image:"formats=png|jpeg,maxw=4096,maxh=4096"`.
+type imageTag struct {
+	formats   []string // The allowed formats registered to the image package
+	minWidth  int
+	minHeight int
+	maxWidth  int
+	maxHeight int
+}
+
+// parseImageTag parses the value of the "image" struct tag.
+func parseImageTag(tag string) (*imageTag, error) {
+	it := &imageTag{}
+	for _, option := range strings.Split(tag, ",") {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(option, "=")
+		if !ok {
+			return nil, fmt.Errorf("option %q has no value", option)
+		}
+		key = strings.TrimSpace(key)
+		switch key {
+		case "formats":
+			for _, format := range strings.Split(value, "|") {
+				format = strings.ToLower(strings.TrimSpace(format))
+				if format == "jpg" {
+					format = "jpeg"
+				}
+				if format != "" {
+					it.formats = append(it.formats, format)
+				}
+			}
+		case "minw", "minh", "maxw", "maxh":
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid %s %q", key, value)
+			}
+			switch key {
+			case "minw":
+				it.minWidth = n
+			case "minh":
+				it.minHeight = n
+			case "maxw":
+				it.maxWidth = n
+			case "maxh":
+				it.maxHeight = n
+			}
+		default:
+			return nil, fmt.Errorf("unknown option %q", key)
+		}
+	}
+	return it, nil
+}
+
+// imageError returns a validation error of the image file.
+func imageError(name, filename, format string, args ...interface{}) *Error {
+	return &Error{
+		Category: ErrorCategoryValidation,
+		Err:      fmt.Errorf("file field %q: file %q %s", name, filename, fmt.Sprintf(format, args...)),
+	}
+}
+
+// checkImage decodes the config of the image from the reader, and returns an
+// error if the image is not allowed by the "image" struct tag.
+func checkImage(name, filename string, r io.Reader, it *imageTag) *Error {
+	config, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return imageError(name, filename, "is not a valid image: %v", err)
+	}
+
+	if len(it.formats) > 0 {
+		allowed := false
+		for _, f := range it.formats {
+			if f == format {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return imageError(name, filename, "has image format %q which is not allowed", format)
+		}
+	}
+
+	switch {
+	case it.maxWidth > 0 && config.Width > it.maxWidth:
+		return imageError(name, filename, "has width %d which exceeds the limit %d", config.Width, it.maxWidth)
+	case it.maxHeight > 0 && config.Height > it.maxHeight:
+		return imageError(name, filename, "has height %d which exceeds the limit %d", config.Height, it.maxHeight)
+	case config.Width < it.minWidth:
+		return imageError(name, filename, "has width %d which is below the minimum %d", config.Width, it.minWidth)
+	case config.Height < it.minHeight:
+		return imageError(name, filename, "has height %d which is below the minimum %d", config.Height, it.minHeight)
+	}
+	return nil
+}
+
+// checkImageFileHeader checks the uploaded image file against the "image"
+// struct tag.
+func checkImageFileHeader(name string, fh *multipart.FileHeader, it *imageTag) *Error {
+	f, err := fh.Open()
+	if err != nil {
+		return &Error{
+			Category: ErrorCategoryDeserialization,
+			Err:      fmt.Errorf("file field %q: %v", name, err),
+		}
+	}
+	defer func() { _ = f.Close() }()
+	return checkImage(name, fh.Filename, f, it)
+}
@@ -0,0 +1,200 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+func TestParseImageTag(t *testing.T) {
+	tests := []struct {
+		tag     string
+		want    *imageTag
+		wantErr string
+	}{
+		{tag: "", want: &imageTag{}},
+		{
+			tag:  "formats=PNG|jpg,maxw=4096,maxh=2048",
+			want: &imageTag{formats: []string{"png", "jpeg"}, maxWidth: 4096, maxHeight: 2048},
+		},
+		{
+			tag:  "minw=16, minh=32",
+			want: &imageTag{minWidth: 16, minHeight: 32},
+		},
+		{tag: "maxw=wide", wantErr: `invalid maxw "wide"`},
+		{tag: "formats", wantErr: `option "formats" has no value`},
+		{tag: "ratio=1", wantErr: `unknown option "ratio"`},
+	}
+	for _, test := range tests {
+		t.Run(test.tag, func(t *testing.T) {
+			got, err := parseImageTag(test.tag)
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestImages(t *testing.T) {
+	encode := func(t *testing.T, format string, width, height int) string {
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		var buf bytes.Buffer
+		var err error
+		switch format {
+		case "png":
+			err = png.Encode(&buf, img)
+		case "jpeg":
+			err = jpeg.Encode(&buf, img, nil)
+		case "gif":
+			err = gif.Encode(&buf, img, nil)
+		}
+		assert.Nil(t, err)
+		return buf.String()
+	}
+
+	tests := []struct {
+		name     string
+		content  func(t *testing.T) string
+		wantErrs Errors
+	}{
+		{
+			name:    "png",
+			content: func(t *testing.T) string { return encode(t, "png", 64, 32) },
+		},
+		{
+			name:    "jpeg",
+			content: func(t *testing.T) string { return encode(t, "jpeg", 32, 64) },
+		},
+		{
+			name:    "format",
+			content: func(t *testing.T) string { return encode(t, "gif", 32, 32) },
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryValidation,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "avatar": file "avatar" has image format "gif" which is not allowed`),
+				},
+			},
+		},
+		{
+			name:    "too wide",
+			content: func(t *testing.T) string { return encode(t, "png", 65, 32) },
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryValidation,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "avatar": file "avatar" has width 65 which exceeds the limit 64`),
+				},
+			},
+		},
+		{
+			name:    "too tall",
+			content: func(t *testing.T) string { return encode(t, "jpeg", 32, 65) },
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryValidation,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "avatar": file "avatar" has height 65 which exceeds the limit 64`),
+				},
+			},
+		},
+		{
+			name:    "too small",
+			content: func(t *testing.T) string { return encode(t, "png", 8, 32) },
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryValidation,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "avatar": file "avatar" has width 8 which is below the minimum 16`),
+				},
+			},
+		},
+		{
+			name:    "not an image",
+			content: func(t *testing.T) string { return "plain text" },
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryValidation,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "avatar": file "avatar" is not a valid image: image: unknown format`),
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content := test.content(t)
+			newRequest := func(t *testing.T) *http.Request {
+				return newMultipartRequest(t, testPart{field: "avatar", filename: "avatar", content: content})
+			}
+
+			t.Run("staged", func(t *testing.T) {
+				type form struct {
+					Avatar *multipart.FileHeader `form:"avatar" image:"formats=png|jpeg,minw=16,maxw=64,maxh=64"`
+				}
+
+				var gotForm form
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}), func(form form, errs Errors) {
+					gotForm = form
+					gotErrs = errs
+				})
+
+				f.ServeHTTP(httptest.NewRecorder(), newRequest(t))
+				assert.Equal(t, test.wantErrs, gotErrs)
+				assert.Equal(t, test.wantErrs == nil, gotForm.Avatar != nil)
+			})
+
+			t.Run("streamed", func(t *testing.T) {
+				type form struct {
+					Avatar *StoredFile `form:"avatar" image:"formats=png|jpeg,minw=16,maxw=64,maxh=64"`
+				}
+
+				storage := NewMemoryStorage()
+				var gotForm form
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}, Options{Storage: storage}), func(form form, errs Errors) {
+					gotForm = form
+					gotErrs = errs
+				})
+
+				f.ServeHTTP(httptest.NewRecorder(), newRequest(t))
+				assert.Equal(t, test.wantErrs, gotErrs)
+				if test.wantErrs != nil {
+					assert.Nil(t, gotForm.Avatar)
+					return
+				}
+
+				// The whole image is stored, including bytes consumed by decoding its
+				// config.
+				rc, err := storage.Open(context.Background(), gotForm.Avatar.Key)
+				assert.Nil(t, err)
+				got, err := io.ReadAll(rc)
+				assert.Nil(t, err)
+				assert.Equal(t, content, string(got))
+			})
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/flamego/flamego"
+)
+
+// Lazy is a model instance of type T that is only decoded and validated on the
+// first call of Get, which is injected into the request context as *Lazy[T] by
+// binding.LazyOf. It is safe for concurrent use.
+type Lazy[T any] struct {
+	once   sync.Once
+	decode func() (T, Errors)
+
+	v    T
+	errs Errors
+}
+
+// Get decodes and validates the model instance on the first call, and returns
+// the same result for all subsequent calls.
+func (l *Lazy[T]) Get() (T, Errors) {
+	l.once.Do(func() {
+		l.v, l.errs = l.decode()
+		l.decode = nil
+	})
+	return l.v, l.errs
+}
+
+// LazyOf returns a middleware handler that injects *binding.Lazy[T] into the
+// request context, which decodes a new instance of T using the decode function,
+// e.g. binding.DecodeJSON, only when it is asked to. Therefore, the request body
+// is left untouched if no handler ever asks for it. The buffered request body
+// and temporary files of the multipart form parsed by Get, if any, are released
+// after the handler chain finishes, and so are files saved to Options.Storage
+// when the handler chain panics. T can be a pointer type, and
+// Options.ErrorHandler and Options.ResetErrors have no effect.
+func LazyOf[T any](decode DecodeFunc, opts ...Options) flamego.Handler {
+	model := modelOf[T]()
+	var opt Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	return flamego.ContextInvoker(func(c flamego.Context) {
+		opt := opt
+		storage := trackStorage(opt.Storage)
+		if storage != nil {
+			opt.Storage = storage
+		}
+
+		multipartForm := c.Request().MultipartForm
+		buffered, _ := BufferedBody(c.Request().Request)
+		c.Map(&Lazy[T]{
+			decode: func() (T, Errors) {
+				obj := reflect.New(modelType(model))
+				errs := decode(c.Request().Request, obj.Interface(), opt)
+				return mappedValue(model, obj).(T), errs
+			},
+		})
+
+		// Run the rest of the handler chain within this handler, so that what is
+		// left by Get is only released afterwards, even if it panics.
+		finished := false
+		defer func() {
+			if !finished {
+				storage.removeAll()
+			}
+			if form := c.Request().MultipartForm; form != nil && form != multipartForm && !opt.KeepMultipartFiles {
+				_ = form.RemoveAll()
+			}
+			if raw, ok := BufferedBody(c.Request().Request); ok && raw != buffered {
+				_ = raw.Close()
+			}
+		}()
+		if !c.ResponseWriter().Written() {
+			c.Next()
+		}
+		finished = true
+	})
+}
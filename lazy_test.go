@@ -0,0 +1,101 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+func TestLazyOf(t *testing.T) {
+	type form struct {
+		Username string `json:"username" validate:"required"`
+	}
+
+	t.Run("decode on demand", func(t *testing.T) {
+		var gotForm *form
+		var gotErrs Errors
+		f := flamego.New()
+		f.Post("/", LazyOf[*form](DecodeJSON), func(lazy *Lazy[*form]) {
+			gotForm, gotErrs = lazy.Get()
+
+			// Subsequent calls return the same result without decoding again.
+			again, _ := lazy.Get()
+			assert.True(t, gotForm == again)
+		})
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"username": ""}`))
+		assert.Nil(t, err)
+
+		f.ServeHTTP(resp, req)
+		assert.Equal(t, &form{}, gotForm)
+		assert.Len(t, gotErrs, 1)
+		assert.Equal(t, ErrorCategoryValidation, gotErrs[0].Category)
+	})
+
+	t.Run("body untouched", func(t *testing.T) {
+		var body []byte
+		f := flamego.New()
+		f.Post("/",
+			LazyOf[form](DecodeJSON),
+			func(c flamego.Context) {
+				c.ResponseWriter().WriteHeader(http.StatusUnauthorized)
+			},
+			func(c flamego.Context, lazy *Lazy[form]) {
+				t.Fatal("unreachable")
+			},
+		)
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"username": "alice"}`))
+		assert.Nil(t, err)
+
+		f.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+		body, err = io.ReadAll(req.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, `{"username": "alice"}`, string(body))
+	})
+
+	t.Run("release multipart form", func(t *testing.T) {
+		type upload struct {
+			Document *multipart.FileHeader `form:"document"`
+		}
+
+		var name string
+		f := flamego.New()
+		f.Post("/", LazyOf[upload](DecodeMultipartForm, Options{MaxMemory: 1}), func(lazy *Lazy[upload]) {
+			got, errs := lazy.Get()
+			assert.Nil(t, errs)
+
+			// The uploaded file is spilled to disk because of the small
+			// Options.MaxMemory.
+			f, err := got.Document.Open()
+			assert.Nil(t, err)
+			defer func() { _ = f.Close() }()
+			osFile, ok := f.(*os.File)
+			assert.True(t, ok)
+			name = osFile.Name()
+		})
+
+		f.ServeHTTP(httptest.NewRecorder(), newMultipartRequest(t, testPart{field: "document", filename: "report.txt", content: strings.Repeat("a", 1024)}))
+
+		assert.NotEmpty(t, name)
+		_, err := os.Stat(name)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
@@ -0,0 +1,96 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/flamego/flamego"
+)
+
+// Default allowed media types of the request body for binders.
+var (
+	jsonMediaTypes          = []string{"application/json", "+json"}
+	yamlMediaTypes          = []string{"application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml", "+yaml"}
+	formMediaTypes          = []string{"application/x-www-form-urlencoded"}
+	multipartFormMediaTypes = []string{"multipart/form-data"}
+	requestMediaTypes       = []string{"application/json", "+json", "application/x-www-form-urlencoded", "multipart/form-data"}
+)
+
+// matchMediaType returns true if the media type matches the pattern, which is
+// either an exact media type, a wildcard subtype like "text/*", or a structured
+// syntax suffix like "+json".
+func matchMediaType(pattern, mediaType string) bool {
+	pattern = strings.ToLower(pattern)
+	switch {
+	case pattern == "*/*":
+		return true
+	case strings.HasPrefix(pattern, "+"):
+		return strings.HasSuffix(mediaType, pattern)
+	case strings.HasSuffix(pattern, "/*"):
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == mediaType
+}
+
+// checkMediaType returns an error if the Content-Type of the request is not one
+// of Options.MediaTypes, or the given default media types of the binder when
+// not set. Requests without Content-Type are always allowed.
+func checkMediaType(r *http.Request, opt Options, defaults []string) *Error {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return &Error{
+			Category: ErrorCategoryUnsupportedMediaType,
+			Err:      fmt.Errorf("parse media type: %v", err),
+		}
+	}
+
+	allowed := opt.MediaTypes
+	if len(allowed) == 0 {
+		allowed = defaults
+	}
+	for _, pattern := range allowed {
+		if matchMediaType(pattern, mediaType) {
+			return nil
+		}
+	}
+	return &Error{
+		Category: ErrorCategoryUnsupportedMediaType,
+		Err:      fmt.Errorf("unsupported media type %q", mediaType),
+	}
+}
+
+// DefaultErrorHandler is an error handler that can be used as
+// Options.ErrorHandler. It responds with status 415 when the media type of the
+// request body is not supported, with status 413 when the request body is too
+// large, with status 408 when reading the request body timed out, and with
+// status 400 for other errors, along with the error messages in plain text.
+func DefaultErrorHandler(c flamego.Context, errs Errors) {
+	status := http.StatusBadRequest
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		switch err.Category {
+		case ErrorCategoryUnsupportedMediaType:
+			status = http.StatusUnsupportedMediaType
+		case ErrorCategoryRequestTooLarge:
+			status = http.StatusRequestEntityTooLarge
+		case ErrorCategoryTimeout:
+			status = http.StatusRequestTimeout
+		}
+		msgs = append(msgs, fmt.Sprintf("%v", err.Err))
+	}
+
+	c.ResponseWriter().Header().Set("Content-Type", "text/plain; charset=utf-8")
+	c.ResponseWriter().WriteHeader(status)
+	_, _ = c.ResponseWriter().Write([]byte(strings.Join(msgs, "\n")))
+}
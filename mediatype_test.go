@@ -0,0 +1,176 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+func TestMediaTypes(t *testing.T) {
+	type form struct {
+		Username string `json:"username" yaml:"username" form:"username"`
+	}
+
+	tests := []struct {
+		name        string
+		handler     flamego.Handler
+		contentType string
+		body        string
+		wantForm    form
+		wantErrs    Errors
+	}{
+		{
+			name:        "json",
+			handler:     JSON(form{}),
+			contentType: "application/json; charset=utf-8",
+			body:        `{"username": "alice"}`,
+			wantForm:    form{Username: "alice"},
+		},
+		{
+			name:        "json suffix",
+			handler:     JSON(form{}),
+			contentType: "application/vnd.acme.user+json",
+			body:        `{"username": "alice"}`,
+			wantForm:    form{Username: "alice"},
+		},
+		{
+			name:     "no content type",
+			handler:  JSON(form{}),
+			body:     `{"username": "alice"}`,
+			wantForm: form{Username: "alice"},
+		},
+		{
+			name:        "form to json",
+			handler:     JSON(form{}),
+			contentType: "application/x-www-form-urlencoded",
+			body:        "username=alice",
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryUnsupportedMediaType,
+					Source:   SourceJSON,
+					Err:      errors.New(`unsupported media type "application/x-www-form-urlencoded"`),
+				},
+			},
+		},
+		{
+			name:        "json to form",
+			handler:     Form(form{}),
+			contentType: "application/json",
+			body:        `{"username": "alice"}`,
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryUnsupportedMediaType,
+					Source:   SourceForm,
+					Err:      errors.New(`unsupported media type "application/json"`),
+				},
+			},
+		},
+		{
+			name:        "yaml",
+			handler:     YAML(form{}),
+			contentType: "application/x-yaml",
+			body:        "username: alice",
+			wantForm:    form{Username: "alice"},
+		},
+		{
+			name:        "custom media types",
+			handler:     YAML(form{}, Options{MediaTypes: []string{"text/*"}}),
+			contentType: "text/plain",
+			body:        "username: alice",
+			wantForm:    form{Username: "alice"},
+		},
+		{
+			name:        "malformed",
+			handler:     JSON(form{}),
+			contentType: "application/",
+			body:        `{"username": "alice"}`,
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryUnsupportedMediaType,
+					Source:   SourceJSON,
+					Err:      errors.New("parse media type: mime: expected token after slash"),
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotForm form
+			var gotErrs Errors
+			f := flamego.New()
+			f.Post("/", test.handler, func(form form, errs Errors) {
+				gotForm = form
+				gotErrs = errs
+			})
+
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(test.body))
+			assert.Nil(t, err)
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+
+			f.ServeHTTP(resp, req)
+
+			assert.Equal(t, test.wantForm, gotForm)
+			assert.Equal(t, test.wantErrs, gotErrs)
+		})
+	}
+}
+
+func TestDefaultErrorHandler(t *testing.T) {
+	type form struct {
+		Username string `json:"username"`
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantCode    int
+		wantBody    string
+	}{
+		{
+			name:        "unsupported media type",
+			contentType: "text/plain",
+			body:        `{"username": "alice"}`,
+			wantCode:    http.StatusUnsupportedMediaType,
+			wantBody:    `unsupported media type "text/plain"`,
+		},
+		{
+			name:        "bad request",
+			contentType: "application/json",
+			body:        `{"username": "alice"`,
+			wantCode:    http.StatusBadRequest,
+			wantBody:    "unexpected EOF",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := flamego.New()
+			f.Post("/", JSON(form{}, Options{ErrorHandler: DefaultErrorHandler}), func() {
+				t.Fatal("unreachable")
+			})
+
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(test.body))
+			assert.Nil(t, err)
+			req.Header.Set("Content-Type", test.contentType)
+
+			f.ServeHTTP(resp, req)
+
+			assert.Equal(t, test.wantCode, resp.Code)
+			assert.Equal(t, test.wantBody, resp.Body.String())
+		})
+	}
+}
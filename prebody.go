@@ -0,0 +1,148 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// headerFields collects all exported fields of the struct type that are tagged
+// with "header". Embedded structs without the tag are walked into as if their
+// fields belong to the outer struct. It returns nil if the type is not a struct.
+func headerFields(typ reflect.Type, index []int, fields []requestField) []requestField {
+	if typ.Kind() != reflect.Struct {
+		return fields
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		typeField := typ.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+
+		name := typeField.Tag.Get("header")
+		if name == "" || name == "-" {
+			if typeField.Anonymous {
+				fields = headerFields(typeField.Type, fieldIndex, fields)
+			}
+			continue
+		}
+		if !typeField.IsExported() {
+			continue
+		}
+		fields = append(fields,
+			requestField{
+				index:     fieldIndex,
+				fieldType: typeField.Type,
+				names:     map[string]string{"header": textproto.CanonicalMIMEHeaderKey(name)},
+			},
+		)
+	}
+	return fields
+}
+
+// fieldNamespace returns the namespace of the field relative to the struct
+// type, e.g. "Embedded.Field", as accepted by the validator.
+func fieldNamespace(typ reflect.Type, index []int) string {
+	names := make([]string, 0, len(index))
+	for _, i := range index {
+		field := typ.Field(i)
+		names = append(names, field.Name)
+		typ = field.Type
+	}
+	return strings.Join(names, ".")
+}
+
+// checkBeforeBody performs checks that do not need the request body, so that
+// the request can be rejected before the body is uploaded, in which case the
+// server replies with the final status instead of "100 Continue" to clients
+// sending "Expect: 100-continue". The declared Content-Length is checked
+// against Options.MaxBodySize, and the header-bound fields are populated into
+// the object and validated.
+func checkBeforeBody(r *http.Request, obj reflect.Value, fields []requestField, opt Options) Errors {
+	if opt.MaxBodySize > 0 && r.ContentLength > opt.MaxBodySize {
+		return Errors{
+			{
+				Category: ErrorCategoryRequestTooLarge,
+				Err:      fmt.Errorf("request body of %d bytes exceeds the limit %d bytes", r.ContentLength, opt.MaxBodySize),
+			},
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	errs := mapHeaderValues(obj.Elem(), fields, r.Header, opt)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	namespaces := make([]string, 0, len(fields))
+	for _, f := range fields {
+		namespaces = append(namespaces, fieldNamespace(obj.Elem().Type(), f.index))
+	}
+	err := opt.Validator.StructPartialCtx(r.Context(), obj.Interface(), namespaces...)
+	if err != nil {
+		errs = append(errs,
+			Error{
+				Category: ErrorCategoryValidation,
+				Source:   SourceHeader,
+				Err:      err,
+			},
+		)
+	}
+	return errs
+}
+
+// mapHeaderValues maps values of the header into the header-bound fields the
+// same way as mapUnlimitedValues, and resets those without values in the
+// header.
+func mapHeaderValues(obj reflect.Value, fields []requestField, header http.Header, opt Options) Errors {
+	for _, f := range fields {
+		structField := obj.FieldByIndex(f.index)
+		structField.Set(reflect.Zero(structField.Type()))
+	}
+	return mapUnlimitedValues(obj, fields, SourceHeader, "header", url.Values(header), opt, nil)
+}
+
+// limitedBody is the request body that reports an error once more than the
+// limit of bytes is read.
+type limitedBody struct {
+	src       io.ReadCloser
+	limit     int64
+	remaining int64
+	exceeded  bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, b.err()
+	}
+
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.src.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		b.exceeded = true
+		return n, b.err()
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *limitedBody) err() error {
+	return fmt.Errorf("request body exceeds the limit %d bytes", b.limit)
+}
+
+func (b *limitedBody) Close() error {
+	return b.src.Close()
+}
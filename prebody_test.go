@@ -0,0 +1,239 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+// trackedReader records whether it has been read.
+type trackedReader struct {
+	io.Reader
+	read bool
+}
+
+func (r *trackedReader) Read(p []byte) (int, error) {
+	r.read = true
+	return r.Reader.Read(p)
+}
+
+func TestCheckBeforeBody(t *testing.T) {
+	type auth struct {
+		Token string `header:"X-Token" json:"-" validate:"required"`
+	}
+	type form struct {
+		auth
+		Username string `json:"username" validate:"required"`
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		header   http.Header
+		body     io.Reader
+		wantForm form
+		wantErrs Errors
+		wantRead bool
+	}{
+		{
+			name:     "ok",
+			header:   http.Header{"X-Token": []string{"secret"}},
+			body:     strings.NewReader(`{"username": "alice"}`),
+			wantForm: form{auth: auth{Token: "secret"}, Username: "alice"},
+			wantRead: true,
+		},
+		{
+			name: "form limits not applied to header",
+			opts: Options{MaxKeys: 1, MaxValuesPerKey: 1, RepeatedKeys: RepeatedKeysReject},
+			header: http.Header{
+				"X-Token":   []string{"secret", "other"},
+				"X-Request": []string{"1"},
+			},
+			body:     strings.NewReader(`{"username": "alice"}`),
+			wantForm: form{auth: auth{Token: "secret"}, Username: "alice"},
+			wantRead: true,
+		},
+		{
+			name: "missing header",
+			body: strings.NewReader(`{"username": "alice"}`),
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryValidation,
+					Source:   SourceHeader,
+				},
+			},
+		},
+		{
+			name:   "declared length too large",
+			opts:   Options{MaxBodySize: 8},
+			header: http.Header{"X-Token": []string{"secret"}},
+			body:   strings.NewReader(`{"username": "alice"}`),
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryRequestTooLarge,
+					Source:   SourceJSON,
+					Err:      errors.New("request body of 21 bytes exceeds the limit 8 bytes"),
+				},
+				{
+					Category: ErrorCategoryValidation,
+					Source:   SourceJSON,
+				},
+			},
+		},
+		{
+			name:   "actual length too large",
+			opts:   Options{MaxBodySize: 8},
+			header: http.Header{"X-Token": []string{"secret"}},
+			body:   io.MultiReader(strings.NewReader(`{"username": "alice"}`)),
+			wantForm: form{
+				auth: auth{Token: "secret"},
+			},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryRequestTooLarge,
+					Source:   SourceJSON,
+					Err:      errors.New("request body exceeds the limit 8 bytes"),
+				},
+				{
+					Category: ErrorCategoryValidation,
+					Source:   SourceJSON,
+				},
+			},
+			wantRead: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotForm form
+			var gotErrs Errors
+			f := flamego.New()
+			f.Post("/", JSON(form{}, test.opts), func(form form, errs Errors) {
+				gotForm = form
+				gotErrs = errs
+			})
+
+			body := &trackedReader{Reader: test.body}
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, "/", body)
+			assert.Nil(t, err)
+			if r, ok := test.body.(*strings.Reader); ok {
+				req.ContentLength = int64(r.Len())
+			}
+			for k, v := range test.header {
+				req.Header[k] = v
+			}
+
+			f.ServeHTTP(resp, req)
+
+			assert.Equal(t, test.wantForm, gotForm)
+			assert.Equal(t, test.wantRead, body.read)
+			// Validation errors are not compared by messages.
+			for i := range gotErrs {
+				if gotErrs[i].Category == ErrorCategoryValidation {
+					assert.NotNil(t, gotErrs[i].Err)
+					gotErrs[i].Err = nil
+				}
+			}
+			assert.Equal(t, test.wantErrs, gotErrs)
+		})
+	}
+
+	t.Run("expect 100-continue", func(t *testing.T) {
+		f := flamego.New()
+		f.Post("/", JSON(form{}, Options{ErrorHandler: DefaultErrorHandler}), func() {
+			t.Fatal("unreachable")
+		})
+		server := httptest.NewServer(f)
+		defer server.Close()
+
+		body := &trackedReader{Reader: bytes.NewReader(bytes.Repeat([]byte("a"), 1<<20))}
+		req, err := http.NewRequest(http.MethodPost, server.URL, body)
+		assert.Nil(t, err)
+		req.ContentLength = 1 << 20
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Expect", "100-continue")
+
+		client := &http.Client{
+			Transport: &http.Transport{ExpectContinueTimeout: 10 * time.Second},
+		}
+		resp, err := client.Do(req)
+		assert.Nil(t, err)
+		defer func() { _ = resp.Body.Close() }()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.False(t, body.read)
+	})
+
+	t.Run("header wins over body", func(t *testing.T) {
+		type form struct {
+			Token    string `header:"X-Token"`
+			Username string `form:"username" json:"username"`
+		}
+
+		tests := []struct {
+			name        string
+			handler     flamego.Handler
+			contentType string
+			body        string
+		}{
+			{
+				name:        "json",
+				handler:     JSON(form{}),
+				contentType: "application/json",
+				body:        `{"Token": "forged", "username": "alice"}`,
+			},
+			{
+				name:        "form",
+				handler:     Form(form{}),
+				contentType: "application/x-www-form-urlencoded",
+				body:        "Token=forged&username=alice",
+			},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				newRequest := func(token string) *http.Request {
+					req, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+					assert.Nil(t, err)
+					req.Header.Set("Content-Type", test.contentType)
+					if token != "" {
+						req.Header.Set("X-Token", token)
+					}
+					return req
+				}
+
+				for token, want := range map[string]form{
+					"secret": {Token: "secret", Username: "alice"},
+					"":       {Username: "alice"},
+				} {
+					var gotForm form
+					f := flamego.New()
+					f.Post("/", test.handler, func(form form) {
+						gotForm = form
+					})
+					f.ServeHTTP(httptest.NewRecorder(), newRequest(token))
+					assert.Equal(t, want, gotForm)
+				}
+
+				if test.name == "json" {
+					var got form
+					errs := DecodeJSON(newRequest("secret"), &got)
+					assert.Nil(t, errs)
+					assert.Equal(t, form{Token: "secret", Username: "alice"}, got)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,23 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package binding
+
+import (
+	"net/http"
+	"time"
+)
+
+// readDeadlineSetter returns the function to set the read deadline on the
+// underlying connection of the response writer. Whether it is supported is
+// only known upon the first call, which returns an error wrapping
+// http.ErrNotSupported if not.
+func readDeadlineSetter(w http.ResponseWriter) func(time.Time) error {
+	if w == nil {
+		return nil
+	}
+	return http.NewResponseController(w).SetReadDeadline
+}
@@ -0,0 +1,18 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build !go1.20
+
+package binding
+
+import (
+	"net/http"
+	"time"
+)
+
+// readDeadlineSetter returns nil because http.ResponseController is not
+// available before Go 1.20.
+func readDeadlineSetter(http.ResponseWriter) func(time.Time) error {
+	return nil
+}
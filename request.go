@@ -0,0 +1,322 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/flamego/flamego"
+)
+
+// requestTags is the list of struct tags that name fields for each part of the
+// request in binding.Request, in the order of precedence from the lowest to the
+// highest.
+var requestTags = []string{"json", "form", "cookie", "header", "query", "param"}
+
+// requestField is a field of the model that is explicitly tagged for at least
+// one source of binding.Request.
+type requestField struct {
+	index     []int             // The index sequence for reflect.Value.FieldByIndex
+	fieldType reflect.Type      // The type of the field
+	tag       reflect.StructTag // The tag of the field
+	names     map[string]string // The field name for each tag it is tagged with
+}
+
+// Request returns a middleware handler that injects a new instance of the model
+// with populated fields and binding.Errors for any deserialization, binding, or
+// validation errors into the request context. The model instance fields are
+// populated from multiple parts of the request, and each field is only
+// populated from parts it is explicitly tagged for:
+//   - "json": the JSON payload of the request body
+//   - "form": the form-urlencoded or multipart form data of the request body
+//   - "cookie": the request cookies
+//   - "header": the request headers
+//   - "query": the URL query parameters
+//   - "param": the bind parameters of the route
+//
+// When a field is tagged for multiple parts, the part comes later in the above
+// list takes precedence as long as it has a value for the field, e.g. a route
+// parameter always overwrites the same field from the request body.
+//
+// The request body is decoded as form data when its Content-Type is either
+// "application/x-www-form-urlencoded" or "multipart/form-data", and as JSON
+// otherwise. An empty request body is not an error. Deserialization and binding
+// errors are attributed to the part of the request they originate from, while
+// validation errors are not attributed to any. Options.Strict only applies to
+// the request body and URL query parameters. Uploaded files are checked the same
+// way as binding.MultipartForm, except that Options.StreamMultipart and
+// Options.Storage are not supported.
+func Request(model interface{}, opts ...Options) flamego.Handler {
+	ensureNotPointer(model)
+	if len(opts) > 0 && (opts[0].StreamMultipart || opts[0].Storage != nil) {
+		panic("binding: Options.StreamMultipart and Options.Storage are not supported by binding.Request")
+	}
+
+	typ := modelType(model)
+	if typ.Kind() != reflect.Struct {
+		panic("binding: only struct can be accepted as binding.Request model")
+	}
+	fields := requestFields(typ, nil, nil)
+	jsonType, jsonFields := requestJSONType(fields)
+	fieldNames := make(map[string]map[string]struct{}, len(requestTags))
+	for _, tag := range requestTags {
+		names := make(map[string]struct{})
+		for _, f := range fields {
+			if name, ok := f.names[tag]; ok {
+				names[name] = struct{}{}
+			}
+		}
+		fieldNames[tag] = names
+	}
+
+	return bind("Request", model, "", requestMediaTypes, opts, func(c flamego.Context, obj reflect.Value, opt Options) Errors {
+		var errs Errors
+		r := c.Request().Request
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "application/x-www-form-urlencoded", "multipart/form-data":
+			var form url.Values
+			var files map[string][]*multipart.FileHeader
+			if mediaType == "multipart/form-data" {
+				var ok bool
+				errs, ok = readMultipartForm(r, obj.Elem(), fieldNames["form"], opt)
+				for i := range errs {
+					errs[i].Source = SourceForm
+				}
+				if !ok {
+					break
+				}
+				if r.MultipartForm != nil {
+					form, files = r.MultipartForm.Value, r.MultipartForm.File
+				}
+			} else {
+				err := r.ParseForm()
+				if err != nil {
+					bodyErr := bodyError(r, err)
+					bodyErr.Source = SourceForm
+					errs = append(errs, bodyErr)
+				}
+				form = r.PostForm
+			}
+			form, formErr := transcodeForm(r, form)
+			if formErr != nil {
+				formErr.Source = SourceForm
+				errs = append(errs, *formErr)
+			}
+			errs = mapRequestValues(obj.Elem(), fields, SourceForm, "form", form, files, fieldNames["form"], opt, errs)
+
+		default:
+			if jsonType == nil || r.Body == nil {
+				break
+			}
+
+			defer func() { _ = r.Body.Close() }()
+			body, bodyErr := textBody(r, opt)
+			if bodyErr != nil {
+				bodyErr.Source = SourceJSON
+				errs = append(errs, *bodyErr)
+				break
+			}
+
+			shadow := reflect.New(jsonType)
+			dec := json.NewDecoder(body)
+			if opt.Strict {
+				dec.DisallowUnknownFields()
+			}
+			err := dec.Decode(shadow.Interface())
+			if err != nil && err != io.EOF {
+				bodyErr := bodyError(r, err)
+				bodyErr.Source = SourceJSON
+				errs = append(errs, bodyErr)
+			} else if opt.RejectInvalidUTF8 {
+				for _, err := range checkStrings(shadow, "", "json", nil) {
+					err.Source = SourceJSON
+					errs = append(errs, err)
+				}
+			}
+			for i, f := range jsonFields {
+				obj.Elem().FieldByIndex(f.index).Set(shadow.Elem().Field(i))
+			}
+		}
+
+		errs = mapUnlimitedValues(obj.Elem(), fields, SourceCookie, "cookie", cookieValues(r), opt, errs)
+		errs = mapUnlimitedValues(obj.Elem(), fields, SourceHeader, "header", url.Values(r.Header), opt, errs)
+
+		query, err := url.ParseQuery(r.URL.RawQuery)
+		if err != nil {
+			errs = append(errs,
+				Error{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceQuery,
+					Err:      err,
+				},
+			)
+		}
+		errs = mapRequestValues(obj.Elem(), fields, SourceQuery, "query", query, nil, fieldNames["query"], opt, errs)
+		return mapRequestValues(obj.Elem(), fields, SourceParams, "param", paramValues(c.Params()), nil, nil, opt, errs)
+	})
+}
+
+// requestFields collects all fields of the struct type that are explicitly
+// tagged for any source of binding.Request. Embedded structs without any of the
+// tags are walked into as if their fields belong to the outer struct.
+func requestFields(typ reflect.Type, index []int, fields []requestField) []requestField {
+	for i := 0; i < typ.NumField(); i++ {
+		typeField := typ.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+
+		names := make(map[string]string)
+		for _, tag := range requestTags {
+			name := typeField.Tag.Get(tag)
+			if tag == "json" {
+				name = strings.Split(name, ",")[0]
+			}
+			if name == "" || name == "-" {
+				continue
+			}
+			if tag == "header" {
+				name = textproto.CanonicalMIMEHeaderKey(name)
+			}
+			names[tag] = name
+		}
+
+		if len(names) == 0 {
+			if typeField.Anonymous && typeField.Type.Kind() == reflect.Struct {
+				fields = requestFields(typeField.Type, fieldIndex, fields)
+			}
+			continue
+		}
+		if typeField.PkgPath != "" {
+			panic(fmt.Sprintf("binding: unexported field %q can not be tagged for binding.Request", typeField.Name))
+		}
+		fields = append(fields,
+			requestField{
+				index:     fieldIndex,
+				fieldType: typeField.Type,
+				tag:       typeField.Tag,
+				names:     names,
+			},
+		)
+	}
+	return fields
+}
+
+// requestJSONType returns a struct type that only consists of fields tagged
+// for the JSON payload, along with the list of those fields in the same order.
+// It returns nil type if there is no such field.
+func requestJSONType(fields []requestField) (reflect.Type, []requestField) {
+	var structFields []reflect.StructField
+	var jsonFields []requestField
+	for _, f := range fields {
+		if _, ok := f.names["json"]; !ok {
+			continue
+		}
+		// The full tag is kept for options like "string" and "omitempty".
+		structFields = append(structFields,
+			reflect.StructField{
+				Name: fmt.Sprintf("Field%d", len(structFields)),
+				Type: f.fieldType,
+				Tag:  reflect.StructTag(fmt.Sprintf("json:%q", f.tag.Get("json"))),
+			},
+		)
+		jsonFields = append(jsonFields, f)
+	}
+	if len(structFields) == 0 {
+		return nil, nil
+	}
+	return reflect.StructOf(structFields), jsonFields
+}
+
+// mapRequestValues maps values of the source into fields that are tagged for
+// it, and attributes any error to the source. Unknown keys are only reported
+// when the set of field names is given and the strict mode is enabled.
+func mapRequestValues(
+	obj reflect.Value,
+	fields []requestField,
+	source Source,
+	tag string,
+	form url.Values,
+	files map[string][]*multipart.FileHeader,
+	fieldNames map[string]struct{},
+	opt Options,
+	errs Errors,
+) Errors {
+	numErrs := len(errs)
+	limitErrs := checkFormLimits(form, files, opt)
+	if len(limitErrs) > 0 {
+		errs = append(errs, limitErrs...)
+	} else {
+		for _, f := range fields {
+			name, ok := f.names[tag]
+			if !ok {
+				continue
+			}
+
+			structField := obj.FieldByIndex(f.index)
+			if inputValue, exists := form[name]; exists {
+				errs = setFormValue(structField, inputValue, name, opt, errs)
+			} else if inputFile, exists := files[name]; exists {
+				setFormFile(structField, inputFile)
+			}
+		}
+
+		if fieldNames != nil && opt.Strict {
+			errs = checkUnknownKeys(fieldNames, form, files, errs)
+		}
+	}
+
+	for i := numErrs; i < len(errs); i++ {
+		errs[i].Source = source
+	}
+	return errs
+}
+
+// mapUnlimitedValues maps values of the source into fields that are tagged for
+// it, and attributes any error to the source. Unlike form data, sources like
+// the header and cookies are not subject to Options.MaxKeys,
+// Options.MaxValuesPerKey and Options.RepeatedKeys, and only the first value is
+// bound into non-slice fields as by http.Header.Get and http.Request.Cookie.
+func mapUnlimitedValues(
+	obj reflect.Value,
+	fields []requestField,
+	source Source,
+	tag string,
+	form url.Values,
+	opt Options,
+	errs Errors,
+) Errors {
+	numErrs := len(errs)
+	for _, f := range fields {
+		name, ok := f.names[tag]
+		if !ok {
+			continue
+		}
+
+		values := form[name]
+		if len(values) == 0 {
+			continue
+		}
+
+		structField := obj.FieldByIndex(f.index)
+		if structField.Kind() != reflect.Slice {
+			values = values[:1]
+		}
+		errs = setFormValue(structField, values, name, opt, errs)
+	}
+
+	for i := numErrs; i < len(errs); i++ {
+		errs[i].Source = source
+	}
+	return errs
+}
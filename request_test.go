@@ -0,0 +1,266 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+func TestRequest(t *testing.T) {
+	t.Run("pointer model", func(t *testing.T) {
+		assert.PanicsWithValue(t,
+			"binding: pointer can not be accepted as binding model",
+			func() {
+				type request struct {
+					ID int `param:"id"`
+				}
+				Request(&request{})
+			},
+		)
+	})
+
+	type pagination struct {
+		Page int `query:"page"`
+	}
+	type request struct {
+		pagination
+		ID      int64  `param:"id" json:"id"`
+		Token   string `header:"Authorization" validate:"required"`
+		Theme   string `cookie:"theme"`
+		Verbose bool   `query:"verbose"`
+		Title   string `json:"title" form:"title" validate:"required"`
+		Body    string `json:"body" form:"body"`
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		body        string
+		want        request
+		wantErrs    []Error
+	}{
+		{
+			name:        "JSON body",
+			url:         "/issues/42?page=3&verbose=true",
+			contentType: "application/json",
+			body:        `{"id": 1, "title": "Hello", "body": "World", "Token": "forged"}`,
+			want: request{
+				pagination: pagination{Page: 3},
+				ID:         42,
+				Token:      "Bearer token",
+				Theme:      "dark",
+				Verbose:    true,
+				Title:      "Hello",
+				Body:       "World",
+			},
+		},
+		{
+			name:        "form body",
+			url:         "/issues/42?title=ignored",
+			contentType: "application/x-www-form-urlencoded",
+			body:        `title=Hello&body=World&id=1`,
+			want: request{
+				ID:    42,
+				Token: "Bearer token",
+				Theme: "dark",
+				Title: "Hello",
+				Body:  "World",
+			},
+		},
+		{
+			name: "empty body",
+			url:  "/issues/42",
+			want: request{
+				ID:    42,
+				Token: "Bearer token",
+				Theme: "dark",
+			},
+			wantErrs: []Error{
+				{Category: ErrorCategoryValidation},
+			},
+		},
+		{
+			name:        "errors from multiple sources",
+			url:         "/issues/abc?verbose=maybe",
+			contentType: "application/json",
+			body:        `{"title": 1}`,
+			want: request{
+				Token: "Bearer token",
+				Theme: "dark",
+			},
+			wantErrs: []Error{
+				{Category: ErrorCategoryDeserialization, Source: SourceJSON},
+				{Category: ErrorCategoryDeserialization, Source: SourceQuery},
+				{Category: ErrorCategoryDeserialization, Source: SourceParams},
+				{Category: ErrorCategoryValidation},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotRequest request
+			var gotErrs Errors
+			f := flamego.New()
+			f.Post("/issues/{id}", Request(request{}), func(request request, errs Errors) {
+				gotRequest = request
+				gotErrs = errs
+			})
+
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, test.url, bytes.NewBufferString(test.body))
+			assert.Nil(t, err)
+
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			req.Header.Set("Authorization", "Bearer token")
+			req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+			f.ServeHTTP(resp, req)
+
+			var got []Error
+			for _, err := range gotErrs {
+				got = append(got, Error{Category: err.Category, Source: err.Source})
+			}
+			assert.Equal(t, test.wantErrs, got)
+			assert.Equal(t, test.want, gotRequest)
+		})
+	}
+
+	t.Run("headers and cookies are not limited as form", func(t *testing.T) {
+		type request struct {
+			Token string   `header:"Authorization"`
+			Tags  []string `header:"X-Tag"`
+			Theme string   `cookie:"theme"`
+		}
+
+		var gotRequest request
+		var gotErrs Errors
+		f := flamego.New()
+		f.Get("/", Request(request{}, Options{MaxKeys: 2, MaxValuesPerKey: 1, RepeatedKeys: RepeatedKeysReject}), func(request request, errs Errors) {
+			gotRequest = request
+			gotErrs = errs
+		})
+
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		assert.Nil(t, err)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Add("X-Tag", "a")
+		req.Header.Add("X-Tag", "b")
+		req.Header.Set("X-Request-Id", "1")
+		req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+		req.AddCookie(&http.Cookie{Name: "theme", Value: "light"})
+		req.AddCookie(&http.Cookie{Name: "lang", Value: "en"})
+		req.AddCookie(&http.Cookie{Name: "session", Value: "1"})
+		f.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Nil(t, gotErrs)
+		assert.Equal(t, request{Token: "Bearer token", Tags: []string{"a", "b"}, Theme: "dark"}, gotRequest)
+	})
+
+	t.Run("json tag options", func(t *testing.T) {
+		type request struct {
+			ID    int64  `json:"id,string"`
+			Title string `json:"title,omitempty" query:"title"`
+		}
+
+		var gotRequest request
+		var gotErrs Errors
+		f := flamego.New()
+		f.Post("/", Request(request{}), func(request request, errs Errors) {
+			gotRequest = request
+			gotErrs = errs
+		})
+
+		req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"id": "42", "title": "Hello"}`))
+		assert.Nil(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		f.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Nil(t, gotErrs)
+		assert.Equal(t, request{ID: 42, Title: "Hello"}, gotRequest)
+	})
+
+	t.Run("multipart form", func(t *testing.T) {
+		type request struct {
+			Title      string                `form:"title"`
+			Attachment *multipart.FileHeader `form:"attachment"`
+		}
+
+		tests := []struct {
+			name      string
+			opts      Options
+			wantTitle string
+			wantFile  bool
+			wantErrs  []Error
+		}{
+			{
+				name:      "accepted",
+				wantTitle: "Hello",
+				wantFile:  true,
+			},
+			{
+				name: "upload limits",
+				opts: Options{MaxFileSize: 4},
+				wantErrs: []Error{
+					{Category: ErrorCategoryRequestTooLarge, Source: SourceForm},
+				},
+			},
+			{
+				name:      "scanner",
+				opts:      Options{Scanner: failingScanner{}},
+				wantTitle: "Hello",
+				wantErrs: []Error{
+					{Category: ErrorCategoryDeserialization, Source: SourceForm},
+				},
+			},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				var gotRequest request
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", Request(request{}, test.opts), func(request request, errs Errors) {
+					gotRequest = request
+					gotErrs = errs
+				})
+
+				req := newMultipartRequest(t,
+					testPart{field: "title", content: "Hello"},
+					testPart{field: "attachment", filename: "notes.txt", content: "hello world"},
+				)
+				f.ServeHTTP(httptest.NewRecorder(), req)
+
+				var got []Error
+				for _, err := range gotErrs {
+					got = append(got, Error{Category: err.Category, Source: err.Source})
+				}
+				assert.Equal(t, test.wantErrs, got)
+				assert.Equal(t, test.wantTitle, gotRequest.Title)
+				assert.Equal(t, test.wantFile, gotRequest.Attachment != nil)
+			})
+		}
+	})
+
+	t.Run("streaming options", func(t *testing.T) {
+		type request struct {
+			Title string `form:"title"`
+		}
+		assert.PanicsWithValue(t,
+			"binding: Options.StreamMultipart and Options.Storage are not supported by binding.Request",
+			func() {
+				Request(request{}, Options{StreamMultipart: true})
+			},
+		)
+	})
+}
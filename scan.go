@@ -0,0 +1,197 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+)
+
+// ScanVerdict is the verdict of scanning an uploaded file.
+type ScanVerdict int
+
+const (
+	// ScanAccepted indicates the file is allowed to reach the handlers.
+	ScanAccepted ScanVerdict = iota
+	// ScanRejected indicates the file is discarded.
+	ScanRejected
+	// ScanQuarantined indicates the file is withheld from the handlers, and
+	// saved to Options.Quarantine when set.
+	ScanQuarantined
+)
+
+// ScanResult is the result of scanning an uploaded file.
+type ScanResult struct {
+	// Verdict is the verdict of the file.
+	Verdict ScanVerdict
+	// Reason is the reason of the verdict, e.g. the name of the detected threat.
+	Reason string
+}
+
+// Scanner inspects uploaded files before they are bound, e.g. by antivirus or
+// content policy engines.
+type Scanner interface {
+	// Scan reads the content of the file part and returns the verdict. Files are
+	// rejected when an error is returned.
+	Scan(ctx context.Context, part *FilePart) (ScanResult, error)
+}
+
+// scanFile scans the file part with Options.Scanner, and returns an error if
+// the file is not accepted. The open function returns a new reader of the
+// content to save quarantined files.
+func scanFile(ctx context.Context, part *FilePart, open func() (io.ReadCloser, error), opt Options) *Error {
+	result, err := opt.Scanner.Scan(ctx, part)
+	if err != nil {
+		return &Error{
+			Category: ErrorCategoryDeserialization,
+			Err:      fmt.Errorf("file field %q: scan file %q: %v", part.FieldName, part.FileName, err),
+		}
+	}
+
+	switch result.Verdict {
+	case ScanAccepted:
+		return nil
+	case ScanQuarantined:
+		if opt.Quarantine != nil {
+			rc, err := open()
+			if err == nil {
+				_, err = opt.Quarantine.Store(ctx, rc)
+				_ = rc.Close()
+			}
+			if err != nil {
+				return &Error{
+					Category: ErrorCategoryDeserialization,
+					Err:      fmt.Errorf("file field %q: quarantine file %q: %v", part.FieldName, part.FileName, err),
+				}
+			}
+		}
+		return &Error{
+			Category: ErrorCategoryValidation,
+			Err:      fmt.Errorf("file field %q: file %q is quarantined: %s", part.FieldName, part.FileName, result.Reason),
+		}
+	}
+	return &Error{
+		Category: ErrorCategoryValidation,
+		Err:      fmt.Errorf("file field %q: file %q is rejected: %s", part.FieldName, part.FileName, result.Reason),
+	}
+}
+
+// scanFileHeader scans the uploaded file with Options.Scanner.
+func scanFileHeader(ctx context.Context, name string, fh *multipart.FileHeader, contentType string, opt Options) *Error {
+	f, err := fh.Open()
+	if err != nil {
+		return &Error{
+			Category: ErrorCategoryDeserialization,
+			Err:      fmt.Errorf("file field %q: %v", name, err),
+		}
+	}
+	defer func() { _ = f.Close() }()
+
+	part := &FilePart{
+		FieldName:   name,
+		FileName:    fh.Filename,
+		Header:      fh.Header,
+		ContentType: contentType,
+		Reader:      f,
+	}
+	return scanFile(ctx, part, func() (io.ReadCloser, error) { return fh.Open() }, opt)
+}
+
+// spoolFilePart saves the content of the file part being streamed to a
+// temporary file, and scans it before the content is handed over. The content
+// of the file part is replaced by the temporary file, and the returned function
+// removes the temporary file.
+func spoolFilePart(ctx context.Context, part *FilePart, opt Options) (func(), *Error) {
+	tmp, err := os.CreateTemp("", "binding-scan-")
+	if err != nil {
+		return nil, &Error{
+			Category: ErrorCategoryDeserialization,
+			Err:      fmt.Errorf("file field %q: %v", part.FieldName, err),
+		}
+	}
+	remove := func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}
+
+	rewind := func() (io.ReadCloser, error) {
+		_, err := tmp.Seek(0, io.SeekStart)
+		return io.NopCloser(tmp), err
+	}
+	_, err = io.Copy(tmp, part.Reader)
+	if err == nil {
+		_, err = rewind()
+	}
+	if err != nil {
+		remove()
+		return nil, &Error{
+			Category: ErrorCategoryDeserialization,
+			Err:      fmt.Errorf("file field %q: %v", part.FieldName, err),
+		}
+	}
+
+	part.Reader = tmp
+	scanErr := scanFile(ctx, part, rewind, opt)
+	if scanErr == nil {
+		_, err = rewind()
+		if err != nil {
+			scanErr = &Error{
+				Category: ErrorCategoryDeserialization,
+				Err:      fmt.Errorf("file field %q: %v", part.FieldName, err),
+			}
+		}
+	}
+	if scanErr != nil {
+		remove()
+		return nil, scanErr
+	}
+	return remove, nil
+}
+
+// eicarSignature is the EICAR anti-virus test file, see
+// https://www.eicar.org/download-anti-malware-testfile/.
+const eicarSignature = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// EICARScanner is a Scanner that detects the EICAR anti-virus test signature,
+// which stands in for real scanners in tests.
+type EICARScanner struct {
+	// Quarantine indicates whether to quarantine detected files instead of
+	// rejecting them.
+	Quarantine bool
+}
+
+var _ Scanner = (*EICARScanner)(nil)
+
+func (s *EICARScanner) Scan(_ context.Context, part *FilePart) (ScanResult, error) {
+	sig := []byte(eicarSignature)
+	buf := make([]byte, 32*1024)
+	var tail []byte // The end of the previous chunk that may contain a partial signature
+	for {
+		n, err := part.Read(buf)
+		if n > 0 {
+			chunk := append(tail, buf[:n]...)
+			if bytes.Contains(chunk, sig) {
+				verdict := ScanRejected
+				if s.Quarantine {
+					verdict = ScanQuarantined
+				}
+				return ScanResult{Verdict: verdict, Reason: "EICAR test signature"}, nil
+			}
+			if len(chunk) >= len(sig) {
+				chunk = chunk[len(chunk)-len(sig)+1:]
+			}
+			tail = append(tail[:0:0], chunk...)
+		}
+		if err == io.EOF {
+			return ScanResult{Verdict: ScanAccepted}, nil
+		} else if err != nil {
+			return ScanResult{}, err
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+// failingScanner is a Scanner that always fails.
+type failingScanner struct{}
+
+func (failingScanner) Scan(context.Context, *FilePart) (ScanResult, error) {
+	return ScanResult{}, errors.New("engine unavailable")
+}
+
+func TestEICARScanner(t *testing.T) {
+	tests := []struct {
+		name    string
+		scanner *EICARScanner
+		content string
+		want    ScanResult
+	}{
+		{
+			name:    "clean",
+			scanner: &EICARScanner{},
+			content: "harmless content",
+			want:    ScanResult{Verdict: ScanAccepted},
+		},
+		{
+			name:    "signature",
+			scanner: &EICARScanner{},
+			content: eicarSignature,
+			want:    ScanResult{Verdict: ScanRejected, Reason: "EICAR test signature"},
+		},
+		{
+			name:    "signature across chunks",
+			scanner: &EICARScanner{Quarantine: true},
+			content: strings.Repeat(" ", 32*1024-10) + eicarSignature,
+			want:    ScanResult{Verdict: ScanQuarantined, Reason: "EICAR test signature"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.scanner.Scan(context.Background(), &FilePart{Reader: strings.NewReader(test.content)})
+			assert.Nil(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestScanner(t *testing.T) {
+	newRequest := func(t *testing.T, content string) *http.Request {
+		return newMultipartRequest(t,
+			testPart{field: "title", content: "report"},
+			testPart{field: "document", filename: "report.txt", content: content},
+		)
+	}
+
+	tests := []struct {
+		name           string
+		scanner        Scanner
+		content        string
+		wantQuarantine bool
+		wantErrs       Errors
+	}{
+		{
+			name:    "accepted",
+			scanner: &EICARScanner{},
+			content: "harmless content",
+		},
+		{
+			name:    "rejected",
+			scanner: &EICARScanner{},
+			content: eicarSignature,
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryValidation,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "document": file "report.txt" is rejected: EICAR test signature`),
+				},
+			},
+		},
+		{
+			name:           "quarantined",
+			scanner:        &EICARScanner{Quarantine: true},
+			content:        eicarSignature,
+			wantQuarantine: true,
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryValidation,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "document": file "report.txt" is quarantined: EICAR test signature`),
+				},
+			},
+		},
+		{
+			name:    "failed",
+			scanner: failingScanner{},
+			content: "harmless content",
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "document": scan file "report.txt": engine unavailable`),
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assertQuarantine := func(t *testing.T, quarantine *MemoryStorage) {
+				if !test.wantQuarantine {
+					assert.Empty(t, quarantine.files)
+					return
+				}
+				assert.Len(t, quarantine.files, 1)
+				for _, data := range quarantine.files {
+					assert.Equal(t, test.content, string(data))
+				}
+			}
+
+			t.Run("staged", func(t *testing.T) {
+				type form struct {
+					Title    string                `form:"title"`
+					Document *multipart.FileHeader `form:"document"`
+				}
+
+				quarantine := NewMemoryStorage()
+				var gotForm form
+				var gotErrs Errors
+				var gotFileErr error
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}, Options{Scanner: test.scanner, Quarantine: quarantine}), func(c flamego.Context, form form, errs Errors) {
+					gotForm = form
+					gotErrs = errs
+					_, _, gotFileErr = c.Request().FormFile("document")
+				})
+
+				f.ServeHTTP(httptest.NewRecorder(), newRequest(t, test.content))
+				assert.Equal(t, test.wantErrs, gotErrs)
+				assert.Equal(t, "report", gotForm.Title)
+				assert.Equal(t, test.wantErrs == nil, gotForm.Document != nil)
+				// Files not accepted are not accessible from the request either.
+				if test.wantErrs != nil {
+					assert.Equal(t, http.ErrMissingFile, gotFileErr)
+				} else {
+					assert.Nil(t, gotFileErr)
+				}
+				assertQuarantine(t, quarantine)
+			})
+
+			t.Run("streamed", func(t *testing.T) {
+				type form struct {
+					Title string `form:"title"`
+				}
+
+				var got []string
+				opts := Options{
+					Scanner:         test.scanner,
+					Quarantine:      NewMemoryStorage(),
+					StreamMultipart: true,
+					FileSinks: map[string]FileSink{
+						"document": func(part *FilePart) error {
+							data, err := io.ReadAll(part)
+							got = append(got, string(data))
+							return err
+						},
+					},
+				}
+
+				var gotForm form
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}, opts), func(form form, errs Errors) {
+					gotForm = form
+					gotErrs = errs
+				})
+
+				f.ServeHTTP(httptest.NewRecorder(), newRequest(t, test.content))
+				assert.Equal(t, test.wantErrs, gotErrs)
+				assert.Equal(t, "report", gotForm.Title)
+				if test.wantErrs != nil {
+					// Files not accepted never reach the sink.
+					assert.Nil(t, got)
+				} else {
+					assert.Equal(t, []string{test.content}, got)
+				}
+				assertQuarantine(t, opts.Quarantine.(*MemoryStorage))
+			})
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sniffLen is the number of bytes considered by http.DetectContentType.
+const sniffLen = 512
+
+// ContentTypeDetector detects the content type of an uploaded file from its
+// first 512 bytes, or fewer if the file is smaller. It returns an empty string
+// to fall back to http.DetectContentType.
+type ContentTypeDetector func(data []byte) string
+
+// detectContentType returns the content type of the data detected by the
+// detector, and falls back to http.DetectContentType.
+func detectContentType(detector ContentTypeDetector, data []byte) string {
+	if detector != nil {
+		if contentType := detector(data); contentType != "" {
+			return contentType
+		}
+	}
+	return http.DetectContentType(data)
+}
+
+// checkFileType returns an error if the filename extension or the detected
+// content type of the file is not allowed by the "file" struct tag.
+func checkFileType(name, filename, contentType string, ft fileTag) *Error {
+	if len(ft.exts) > 0 {
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+		allowed := false
+		for _, e := range ft.exts {
+			if e == ext {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &Error{
+				Category: ErrorCategoryValidation,
+				Err:      fmt.Errorf("file field %q: file %q has extension %q which is not allowed", name, filename, ext),
+			}
+		}
+	}
+
+	if len(ft.types) > 0 {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		allowed := false
+		for _, typ := range ft.types {
+			if matchMediaType(typ, mediaType) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &Error{
+				Category: ErrorCategoryValidation,
+				Err:      fmt.Errorf("file field %q: file %q has content type %q which is not allowed", name, filename, mediaType),
+			}
+		}
+	}
+	return nil
+}
+
+// sniffFileHeader detects the content type of the uploaded file.
+func sniffFileHeader(fh *multipart.FileHeader, detector ContentTypeDetector) (string, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return detectContentType(detector, buf[:n]), nil
+}
+
+// inspectFiles sanitizes filenames and detects content types of uploaded files
+// of the form fields in the set of field names, and replaces the Content-Type
+// in their headers with the detected ones for fields restricted by the "types"
+// option of the "file" struct tag, and so are their checksums. Files
+// rejected by unsafe filenames, the "file" and "image" struct tags, declared
+// checksums or the scanner are removed from the form along with their
+// temporary files, and errors are returned for them.
+func inspectFiles(
+	ctx context.Context,
+	form *multipart.Form,
+	fieldNames map[string]struct{},
+	tags map[string]fileTag,
+	opt Options,
+) Errors {
+	names := make([]string, 0, len(form.File))
+	for name := range form.File {
+		if _, ok := fieldNames[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var errs Errors
+	rejected := &multipart.Form{File: make(map[string][]*multipart.FileHeader)}
+	for _, name := range names {
+		var fhs []*multipart.FileHeader
+		for _, fh := range form.File[name] {
+			err := inspectFile(ctx, name, fh, tags[name], opt)
+			if err != nil {
+				errs = append(errs, *err)
+				rejected.File[name] = append(rejected.File[name], fh)
+				continue
+			}
+			fhs = append(fhs, fh)
+		}
+		if len(fhs) > 0 {
+			form.File[name] = fhs
+		} else {
+			delete(form.File, name)
+		}
+	}
+	_ = rejected.RemoveAll()
+	return errs
+}
+
+// inspectFile sanitizes the filename and detects the content type of the
+// uploaded file, and returns an error if the file is rejected.
+func inspectFile(ctx context.Context, name string, fh *multipart.FileHeader, ft fileTag, opt Options) *Error {
+	filename, err := checkFilename(name, rawFilename(fh.Header, fh.Filename), opt)
+	if err != nil {
+		return err
+	}
+	fh.Filename = filename
+
+	contentType, sniffErr := sniffFileHeader(fh, opt.DetectContentType)
+	if sniffErr != nil {
+		return &Error{
+			Category: ErrorCategoryDeserialization,
+			Err:      fmt.Errorf("file field %q: %v", name, sniffErr),
+		}
+	}
+
+	// The client-supplied Content-Type is only replaced for fields restricted by
+	// the detected types, and left as-is for others, e.g. "text/csv".
+	if len(ft.types) > 0 {
+		if fh.Header == nil {
+			fh.Header = make(textproto.MIMEHeader)
+		}
+		fh.Header.Set("Content-Type", contentType)
+	}
+	if err := checkFileType(name, fh.Filename, contentType, ft); err != nil {
+		return err
+	}
+	if ft.image != nil {
+		if err := checkImageFileHeader(name, fh, ft.image); err != nil {
+			return err
+		}
+	}
+	if err := checksumFileHeader(name, fh, opt); err != nil {
+		return err
+	}
+	if opt.Scanner != nil {
+		return scanFileHeader(ctx, name, fh, contentType, opt)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+func TestFileTypes(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n" + "image data"
+	exe := "MZ" + "\x90\x00" + "executable"
+
+	type file struct {
+		field, name, contentType, content string
+	}
+	newRequest := func(t *testing.T, files []file) *http.Request {
+		parts := make([]testPart, 0, len(files))
+		for _, f := range files {
+			parts = append(parts,
+				testPart{
+					field:    f.field,
+					filename: f.name,
+					content:  f.content,
+					header:   map[string]string{"Content-Type": f.contentType},
+				},
+			)
+		}
+		return newMultipartRequest(t, parts...)
+	}
+
+	tests := []struct {
+		name             string
+		opts             Options
+		files            []file
+		wantHeaders      map[string]string // The Content-Type in headers of staged files
+		wantContentTypes map[string]string
+		wantErrs         Errors
+	}{
+		{
+			name: "allowed",
+			files: []file{
+				{"avatar", "me.PNG", "image/png", png},
+				{"document", "notes.txt", "application/pdf", "plain text"},
+			},
+			wantHeaders: map[string]string{
+				"avatar":   "image/png",
+				"document": "application/pdf",
+			},
+			wantContentTypes: map[string]string{
+				"avatar":   "image/png",
+				"document": "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name: "renamed executable",
+			files: []file{
+				{"avatar", "me.png", "image/png", exe},
+				{"document", "notes.txt", "text/plain", "plain text"},
+			},
+			wantHeaders: map[string]string{
+				"document": "text/plain",
+			},
+			wantContentTypes: map[string]string{
+				"document": "text/plain; charset=utf-8",
+			},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryValidation,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "avatar": file "me.png" has content type "application/octet-stream" which is not allowed`),
+				},
+			},
+		},
+		{
+			name: "extension",
+			files: []file{
+				{"avatar", "me.gif", "image/gif", png},
+			},
+			wantHeaders:      map[string]string{},
+			wantContentTypes: map[string]string{},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryValidation,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "avatar": file "me.gif" has extension "gif" which is not allowed`),
+				},
+			},
+		},
+		{
+			name: "detector",
+			opts: Options{
+				DetectContentType: func(data []byte) string {
+					if bytes.HasPrefix(data, []byte("MZ")) {
+						return "application/vnd.microsoft.portable-executable"
+					}
+					return ""
+				},
+			},
+			files: []file{
+				{"avatar", "me.png", "image/png", png},
+				{"document", "setup.txt", "text/plain", exe},
+			},
+			wantHeaders: map[string]string{
+				"avatar":   "image/png",
+				"document": "text/plain",
+			},
+			wantContentTypes: map[string]string{
+				"avatar":   "image/png",
+				"document": "application/vnd.microsoft.portable-executable",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Run("staged", func(t *testing.T) {
+				type form struct {
+					Avatar   *multipart.FileHeader `form:"avatar" file:"types=image/png|image/jpeg,ext=png|jpg"`
+					Document *multipart.FileHeader `form:"document"`
+				}
+
+				var gotForm form
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}, test.opts), func(form form, errs Errors) {
+					gotForm = form
+					gotErrs = errs
+				})
+
+				f.ServeHTTP(httptest.NewRecorder(), newRequest(t, test.files))
+
+				gotContentTypes := make(map[string]string)
+				if gotForm.Avatar != nil {
+					gotContentTypes["avatar"] = gotForm.Avatar.Header.Get("Content-Type")
+				}
+				if gotForm.Document != nil {
+					gotContentTypes["document"] = gotForm.Document.Header.Get("Content-Type")
+				}
+				assert.Equal(t, test.wantHeaders, gotContentTypes)
+				assert.Equal(t, test.wantErrs, gotErrs)
+			})
+
+			t.Run("streamed", func(t *testing.T) {
+				type form struct {
+					Avatar   *StoredFile `form:"avatar" file:"types=image/png|image/jpeg,ext=png|jpg"`
+					Document *StoredFile `form:"document"`
+				}
+
+				opts := test.opts
+				opts.Storage = NewMemoryStorage()
+				var gotForm form
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}, opts), func(form form, errs Errors) {
+					gotForm = form
+					gotErrs = errs
+				})
+
+				f.ServeHTTP(httptest.NewRecorder(), newRequest(t, test.files))
+
+				gotContentTypes := make(map[string]string)
+				if gotForm.Avatar != nil {
+					gotContentTypes["avatar"] = gotForm.Avatar.ContentType
+				}
+				if gotForm.Document != nil {
+					gotContentTypes["document"] = gotForm.Document.ContentType
+				}
+				assert.Equal(t, test.wantContentTypes, gotContentTypes)
+				assert.Equal(t, test.wantErrs, gotErrs)
+			})
+		})
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Storage is the backend that file parts of multipart forms are streamed into.
+type Storage interface {
+	// Store saves all content of the reader and returns the key to access it.
+	Store(ctx context.Context, r io.Reader) (key string, err error)
+	// Open returns the reader of the content with the key.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Remove deletes the content with the key.
+	Remove(ctx context.Context, key string) error
+}
+
+// StoredFile is a file part of the multipart form that has been saved to
+// Options.Storage. Model fields of StoredFile, *StoredFile and []StoredFile are
+// populated by binding.MultipartForm.
+type StoredFile struct {
+	// Key is the key to access the file in the storage.
+	Key string
+	// Filename is the sanitized filename given by the client, see
+	// Options.RejectUnsafeFilenames.
+	Filename string
+	// Size is the size of the file in bytes.
+	Size int64
+	// ContentType is the content type detected from the content of the file, see
+	// Options.DetectContentType.
+	ContentType string
+	// Checksum is the hex-encoded SHA-256 checksum of the content of the file.
+	Checksum string
+	// Checksums contains the hex-encoded checksums of the content of the file
+	// keyed by their hash algorithms, see Options.Checksums.
+	Checksums map[string]string
+}
+
+// storedFileType is the type of binding.StoredFile.
+var storedFileType = reflect.TypeOf(StoredFile{})
+
+// isStoredFileType returns true if the type can be populated with stored files.
+func isStoredFileType(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice:
+		typ = typ.Elem()
+	}
+	return typ == storedFileType
+}
+
+// storedFileFields collects form field names of all fields that can be
+// populated with stored files.
+func storedFileFields(typ reflect.Type, tag string, names map[string]struct{}) map[string]struct{} {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return names
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		typeField := typ.Field(i)
+		if !typeField.IsExported() {
+			continue
+		}
+		if isStoredFileType(typeField.Type) {
+			names[formFieldName(typeField, tag)] = struct{}{}
+			continue
+		}
+
+		fieldType := typeField.Type
+		if fieldType.Kind() == reflect.Ptr && typeField.Anonymous {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct {
+			names = storedFileFields(fieldType, tag, names)
+		}
+	}
+	return names
+}
+
+// setStoredFiles populates fields of the object with stored files by their form
+// field names.
+func setStoredFiles(obj reflect.Value, files map[string][]StoredFile, tag string) {
+	if obj.Kind() == reflect.Ptr {
+		obj = obj.Elem()
+	}
+	typ := obj.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		typeField := typ.Field(i)
+		structField := obj.Field(i)
+		if !structField.CanSet() {
+			continue
+		}
+
+		if !isStoredFileType(typeField.Type) {
+			if typeField.Type.Kind() == reflect.Ptr && typeField.Anonymous {
+				if !structField.IsNil() {
+					setStoredFiles(structField, files, tag)
+					continue
+				}
+				structField.Set(reflect.New(typeField.Type.Elem()))
+				setStoredFiles(structField, files, tag)
+				if structField.Elem().IsZero() {
+					structField.Set(reflect.Zero(structField.Type()))
+				}
+			} else if typeField.Type.Kind() == reflect.Struct {
+				setStoredFiles(structField, files, tag)
+			}
+			continue
+		}
+
+		stored, ok := files[formFieldName(typeField, tag)]
+		if !ok || len(stored) == 0 {
+			continue
+		}
+		switch typeField.Type.Kind() {
+		case reflect.Slice:
+			structField.Set(reflect.ValueOf(stored))
+		case reflect.Ptr:
+			structField.Set(reflect.ValueOf(&stored[0]))
+		default:
+			structField.Set(reflect.ValueOf(stored[0]))
+		}
+	}
+}
+
+// storeFile saves the content of the file part to the storage, and returns the
+// metadata of the stored file. Checksums are taken from the file part, which
+// must be computing the SHA-256 checksum.
+func storeFile(ctx context.Context, storage Storage, part *FilePart) (StoredFile, error) {
+	counter := &countingReader{r: part}
+	key, err := storage.Store(ctx, counter)
+	if err != nil {
+		return StoredFile{}, err
+	}
+	return StoredFile{
+		Key:         key,
+		Filename:    part.FileName,
+		Size:        counter.n,
+		ContentType: part.ContentType,
+		Checksum:    part.Checksums["sha256"],
+		Checksums:   part.Checksums,
+	}, nil
+}
+
+// trackedStorage is a Storage that records keys of files saved while binding a
+// request, so that they can be removed when the binding fails.
+type trackedStorage struct {
+	Storage
+	lock sync.Mutex
+	keys []string
+}
+
+// trackStorage returns a trackedStorage of the storage, or nil if the storage
+// is nil.
+func trackStorage(storage Storage) *trackedStorage {
+	if storage == nil {
+		return nil
+	}
+	return &trackedStorage{Storage: storage}
+}
+
+func (s *trackedStorage) Store(ctx context.Context, r io.Reader) (string, error) {
+	key, err := s.Storage.Store(ctx, r)
+	if err != nil {
+		return "", err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.keys = append(s.keys, key)
+	return key, nil
+}
+
+func (s *trackedStorage) Remove(ctx context.Context, key string) error {
+	s.lock.Lock()
+	for i := range s.keys {
+		if s.keys[i] == key {
+			s.keys = append(s.keys[:i], s.keys[i+1:]...)
+			break
+		}
+	}
+	s.lock.Unlock()
+	return s.Storage.Remove(ctx, key)
+}
+
+// stored returns true if any file is saved and not removed.
+func (s *trackedStorage) stored() bool {
+	if s == nil {
+		return false
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.keys) > 0
+}
+
+// removeAll removes all files saved and not removed. It does not use the
+// request context which may have been canceled.
+func (s *trackedStorage) removeAll() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	keys := s.keys
+	s.keys = nil
+	s.lock.Unlock()
+	for _, key := range keys {
+		_ = s.Storage.Remove(context.Background(), key)
+	}
+}
+
+// randomKey returns a random hex-encoded key.
+func randomKey() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// FileSystemStorage is a Storage that saves files in a directory.
+type FileSystemStorage struct {
+	dir string
+}
+
+var _ Storage = (*FileSystemStorage)(nil)
+
+// NewFileSystemStorage returns a new FileSystemStorage that saves files in the
+// directory, which is created if it does not exist.
+func NewFileSystemStorage(dir string) (*FileSystemStorage, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSystemStorage{dir: dir}, nil
+}
+
+// path returns the path of the file with the key, the key must be a plain name
+// generated by the storage.
+func (s *FileSystemStorage) path(key string) (string, error) {
+	if key == "" || key != filepath.Base(key) || strings.HasPrefix(key, ".") {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(s.dir, key), nil
+}
+
+func (s *FileSystemStorage) Store(_ context.Context, r io.Reader) (string, error) {
+	key, err := randomKey()
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.OpenFile(filepath.Join(s.dir, key), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return key, nil
+}
+
+func (s *FileSystemStorage) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (s *FileSystemStorage) Remove(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// MemoryStorage is a Storage that keeps files in memory, which is mostly useful
+// for testing.
+type MemoryStorage struct {
+	lock  sync.RWMutex
+	files map[string][]byte
+}
+
+var _ Storage = (*MemoryStorage)(nil)
+
+// NewMemoryStorage returns a new MemoryStorage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		files: make(map[string][]byte),
+	}
+}
+
+func (s *MemoryStorage) Store(_ context.Context, r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	key, err := randomKey()
+	if err != nil {
+		return "", err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.files[key] = data
+	return key, nil
+}
+
+func (s *MemoryStorage) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	data, ok := s.files[key]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *MemoryStorage) Remove(_ context.Context, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.files[key]; !ok {
+		return os.ErrNotExist
+	}
+	delete(s.files, key)
+	return nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFileSystemStorage(t.TempDir())
+	assert.Nil(t, err)
+
+	for name, storage := range map[string]Storage{
+		"file system": fs,
+		"memory":      NewMemoryStorage(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			key, err := storage.Store(ctx, strings.NewReader("hello"))
+			assert.Nil(t, err)
+
+			rc, err := storage.Open(ctx, key)
+			assert.Nil(t, err)
+			data, err := io.ReadAll(rc)
+			assert.Nil(t, err)
+			assert.Nil(t, rc.Close())
+			assert.Equal(t, "hello", string(data))
+
+			assert.Nil(t, storage.Remove(ctx, key))
+			_, err = storage.Open(ctx, key)
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+
+	t.Run("invalid key", func(t *testing.T) {
+		_, err := fs.Open(ctx, "../etc/passwd")
+		assert.EqualError(t, err, `invalid key "../etc/passwd"`)
+	})
+}
+
+func TestMultipartFormStorage(t *testing.T) {
+	type form struct {
+		Title  string       `form:"title"`
+		Avatar StoredFile   `form:"avatar"`
+		Cover  *StoredFile  `form:"cover"`
+		Photos []StoredFile `form:"photos"`
+	}
+
+	png := "\x89PNG\r\n\x1a\n" + "image data"
+	req := newMultipartRequest(t,
+		testPart{field: "title", content: "holiday"},
+		// A client must not be able to forge stored files by values.
+		testPart{field: "Key", content: "forged"},
+		testPart{field: "avatar", filename: "me.png", content: png},
+		testPart{field: "photos", filename: "beach.txt", content: "sea"},
+		testPart{field: "photos", filename: "sunset.txt", content: "sun"},
+	)
+
+	storage := NewMemoryStorage()
+	var gotForm form
+	var gotErrs Errors
+	f := flamego.New()
+	f.Post("/", MultipartForm(form{}, Options{Storage: storage}), func(form form, errs Errors) {
+		gotForm = form
+		gotErrs = errs
+	})
+
+	f.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Nil(t, gotErrs)
+
+	checksum := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	assert.Equal(t, "holiday", gotForm.Title)
+	assert.Nil(t, gotForm.Cover)
+	assert.Equal(t,
+		StoredFile{
+			Key:         gotForm.Avatar.Key,
+			Filename:    "me.png",
+			Size:        int64(len(png)),
+			ContentType: "image/png",
+			Checksum:    checksum(png),
+			Checksums:   map[string]string{"sha256": checksum(png)},
+		},
+		gotForm.Avatar,
+	)
+	assert.Len(t, gotForm.Photos, 2)
+	assert.Equal(t, "sunset.txt", gotForm.Photos[1].Filename)
+	assert.Equal(t, "text/plain; charset=utf-8", gotForm.Photos[1].ContentType)
+	assert.Equal(t, checksum("sun"), gotForm.Photos[1].Checksum)
+
+	rc, err := storage.Open(context.Background(), gotForm.Photos[0].Key)
+	assert.Nil(t, err)
+	data, err := io.ReadAll(rc)
+	assert.Nil(t, err)
+	assert.Equal(t, "sea", string(data))
+}
+
+func TestMultipartFormStorageCleanup(t *testing.T) {
+	type form struct {
+		Title  string     `form:"title" validate:"required"`
+		Avatar StoredFile `form:"avatar"`
+	}
+
+	newRequest := func(t *testing.T, title string) *http.Request {
+		return newMultipartRequest(t,
+			testPart{field: "title", content: title},
+			testPart{field: "avatar", filename: "me.txt", content: "avatar"},
+		)
+	}
+
+	tests := []struct {
+		name      string
+		title     string
+		handler   func(form form)
+		wantFiles int
+	}{
+		{
+			name:      "ok",
+			title:     "holiday",
+			handler:   func(form form) {},
+			wantFiles: 1,
+		},
+		{
+			name:      "invalid",
+			handler:   func(form form) {},
+			wantFiles: 0,
+		},
+		{
+			name:      "panic",
+			title:     "holiday",
+			handler:   func(form form) { panic("unexpected") },
+			wantFiles: 0,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Run("flamego", func(t *testing.T) {
+				storage := NewMemoryStorage()
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}, Options{Storage: storage}), test.handler)
+
+				func() {
+					defer func() { _ = recover() }()
+					f.ServeHTTP(httptest.NewRecorder(), newRequest(t, test.title))
+				}()
+				assert.Len(t, storage.files, test.wantFiles)
+			})
+
+			t.Run("error handler", func(t *testing.T) {
+				storage := NewMemoryStorage()
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}, Options{Storage: storage, ErrorHandler: DefaultErrorHandler}), test.handler)
+
+				func() {
+					defer func() { _ = recover() }()
+					f.ServeHTTP(httptest.NewRecorder(), newRequest(t, test.title))
+				}()
+				assert.Len(t, storage.files, test.wantFiles)
+			})
+
+			t.Run("middleware", func(t *testing.T) {
+				storage := NewMemoryStorage()
+				handler := Middleware(DecodeMultipartForm, form{}, Options{Storage: storage})(
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						v, _ := ValueFromContext[form](r.Context())
+						test.handler(v)
+					}),
+				)
+
+				func() {
+					defer func() { _ = recover() }()
+					handler.ServeHTTP(httptest.NewRecorder(), newRequest(t, test.title))
+				}()
+				assert.Len(t, storage.files, test.wantFiles)
+			})
+
+			t.Run("lazy", func(t *testing.T) {
+				storage := NewMemoryStorage()
+				f := flamego.New()
+				f.Post("/", LazyOf[form](DecodeMultipartForm, Options{Storage: storage}), func(lazy *Lazy[form]) {
+					v, _ := lazy.Get()
+					test.handler(v)
+				})
+
+				func() {
+					defer func() { _ = recover() }()
+					f.ServeHTTP(httptest.NewRecorder(), newRequest(t, test.title))
+				}()
+				assert.Len(t, storage.files, test.wantFiles)
+			})
+		})
+	}
+
+	t.Run("decode", func(t *testing.T) {
+		storage := NewMemoryStorage()
+		var v form
+		errs := DecodeMultipartForm(newRequest(t, ""), &v, Options{Storage: storage})
+		assert.Len(t, errs, 1)
+		assert.Empty(t, storage.files)
+	})
+}
@@ -0,0 +1,281 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"reflect"
+)
+
+// FilePart is a file part of the multipart form being streamed.
+type FilePart struct {
+	// FieldName is the form field name of the part.
+	FieldName string
+	// FileName is the sanitized filename given by the client, see
+	// Options.RejectUnsafeFilenames.
+	FileName string
+	// Header is the MIME header of the part, its Content-Type is supplied by the
+	// client.
+	Header textproto.MIMEHeader
+	// ContentType is the content type detected from the first bytes of the
+	// content, see Options.DetectContentType.
+	ContentType string
+	// Checksums contains the hex-encoded checksums of the content keyed by their
+	// hash algorithms, which is only populated once the content is read to the
+	// end, see Options.Checksums.
+	Checksums map[string]string
+	// Reader reads the content of the file, and fails once the file exceeds the
+	// upload limits in the options.
+	io.Reader
+}
+
+// FileSink consumes the content of a file part when streaming the multipart
+// form, e.g. piping it to storage. The part must not be retained after the
+// function returns.
+type FileSink func(part *FilePart) error
+
+// defaultMaxStreamParts is the default maximum number of parts in the streamed
+// multipart form, which is the same as the limit of mime/multipart.
+const defaultMaxStreamParts = 1000
+
+// streamMultipartForm populates the object by walking parts of the multipart
+// form from the request body one by one, without staging them in memory or
+// temporary files. Values are collected up to Options.MaxMemory bytes in total,
+// and file parts are handed to Options.FileSinks of their fields, or saved to
+// Options.Storage for fields of binding.StoredFile, as they arrive. Other file
+// parts are discarded, and reported as unknown fields when Options.Strict is
+// set. Reading stops at the first part that exceeds the upload limits, and file
+// parts with unsafe filenames, disallowed types or images, or not accepted by
+// Options.Scanner are skipped.
+func streamMultipartForm(r *http.Request, obj reflect.Value, opt Options) Errors {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return Errors{bodyError(r, err)}
+	}
+
+	var errs Errors
+	form := make(url.Values)
+	var unknownFiles []string
+	stored := make(map[string][]StoredFile)
+	var storable map[string]struct{}
+	if opt.Storage != nil {
+		storable = storedFileFields(obj.Type(), "form", make(map[string]struct{}))
+	}
+	if opt.MaxParts <= 0 {
+		opt.MaxParts = defaultMaxStreamParts
+	}
+	limiter := newUploadLimiter(obj, opt)
+	remaining := opt.MaxMemory
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			errs = append(errs, bodyError(r, err))
+			break
+		}
+
+		if limitErr := limiter.addPart(); limitErr != nil {
+			errs = append(errs, *limitErr)
+			break
+		}
+
+		name := part.FormName()
+		if name == "" {
+			_ = part.Close()
+			continue
+		}
+
+		if part.FileName() != "" {
+			if limitErr := limiter.addFile(name); limitErr != nil {
+				errs = append(errs, *limitErr)
+				break
+			}
+
+			filename, nameErr := checkFilename(name, rawFilename(part.Header, part.FileName()), opt)
+			if nameErr != nil {
+				_ = part.Close()
+				errs = append(errs, *nameErr)
+				continue
+			}
+
+			content := &limitedFileReader{
+				r:        part,
+				limiter:  limiter,
+				name:     name,
+				filename: filename,
+			}
+			br := bufio.NewReaderSize(content, sniffLen)
+			head, peekErr := br.Peek(sniffLen)
+			if content.err != nil {
+				errs = append(errs, *content.err)
+				break
+			} else if peekErr != nil && peekErr != io.EOF {
+				errs = append(errs, bodyError(r, peekErr))
+				break
+			}
+			file := &FilePart{
+				FieldName:   name,
+				FileName:    filename,
+				Header:      part.Header,
+				ContentType: detectContentType(opt.DetectContentType, head),
+				Reader:      br,
+			}
+			typeErr := checkFileType(name, file.FileName, file.ContentType, limiter.fields[name])
+			if typeErr == nil && limiter.fields[name].image != nil {
+				// Replay the bytes consumed by decoding the image config.
+				var consumed bytes.Buffer
+				typeErr = checkImage(name, file.FileName, io.TeeReader(br, &consumed), limiter.fields[name].image)
+				if content.err != nil {
+					errs = append(errs, *content.err)
+					break
+				}
+				file.Reader = io.MultiReader(&consumed, br)
+			}
+			if typeErr != nil {
+				_ = part.Close()
+				errs = append(errs, *typeErr)
+				continue
+			}
+
+			sink, hasSink := opt.FileSinks[name]
+			_, isStorable := storable[name]
+
+			declared := declaredChecksums(part.Header)
+			algorithms := opt.Checksums
+			if !hasSink && isStorable {
+				// StoredFile.Checksum is always the SHA-256 checksum.
+				algorithms = append(algorithms[:len(algorithms):len(algorithms)], "sha256")
+			}
+			checksums := newChecksummer(algorithms, declared)
+			file.Reader = &checksumReader{r: file.Reader, c: checksums, part: file}
+			removeSpooled := func() {}
+			if opt.Scanner != nil && (hasSink || isStorable) {
+				remove, scanErr := spoolFilePart(r.Context(), file, opt)
+				if content.err != nil {
+					errs = append(errs, *content.err)
+					break
+				} else if scanErr != nil {
+					_ = part.Close()
+					errs = append(errs, *scanErr)
+					continue
+				}
+				removeSpooled = remove
+			}
+
+			var err error
+			var storedFile *StoredFile
+			consumed := true
+			if hasSink {
+				err = sink(file)
+			} else if isStorable {
+				var f StoredFile
+				f, err = storeFile(r.Context(), opt.Storage, file)
+				if err == nil {
+					storedFile = &f
+				}
+			} else {
+				consumed = false
+				unknownFiles = append(unknownFiles, name)
+			}
+			if err == nil && (!consumed || len(declared) > 0) {
+				// Read the rest of the content within the limits to skip the file or
+				// to verify checksums.
+				_, err = io.Copy(io.Discard, file.Reader)
+			}
+			removeSpooled()
+			if content.err != nil {
+				errs = append(errs, *content.err)
+				break
+			} else if err != nil {
+				errs = append(errs,
+					Error{
+						Category: ErrorCategoryDeserialization,
+						Err:      fmt.Errorf("file field %q: %v", name, err),
+					},
+				)
+				break
+			}
+			_ = part.Close()
+			if !consumed {
+				continue
+			}
+
+			if checksumErr := checksums.verify(name, file.FileName, declared); checksumErr != nil {
+				if storedFile != nil {
+					_ = opt.Storage.Remove(r.Context(), storedFile.Key)
+				}
+				errs = append(errs, *checksumErr)
+				continue
+			}
+			if storedFile != nil {
+				stored[name] = append(stored[name], *storedFile)
+			}
+			continue
+		}
+
+		var buf bytes.Buffer
+		n, err := io.CopyN(&buf, part, remaining+1)
+		if err != nil && err != io.EOF {
+			errs = append(errs, bodyError(r, err))
+			break
+		}
+		remaining -= n
+		if remaining < 0 {
+			errs = append(errs,
+				Error{
+					Category: ErrorCategoryDeserialization,
+					Err:      fmt.Errorf("form values exceed the limit %d bytes", opt.MaxMemory),
+				},
+			)
+			break
+		}
+		_ = part.Close()
+		form[name] = append(form[name], buf.String())
+
+		// Only the key of the part is checked, the form has been within the limits
+		// before the part.
+		if opt.MaxKeys > 0 && len(form) > opt.MaxKeys {
+			return append(errs,
+				Error{
+					Category: ErrorCategoryDeserialization,
+					Err:      fmt.Errorf("form has %d keys which exceeds the limit %d", len(form), opt.MaxKeys),
+				},
+			)
+		}
+		if opt.MaxValuesPerKey > 0 && len(form[name]) > opt.MaxValuesPerKey {
+			return append(errs,
+				Error{
+					Category: ErrorCategoryDeserialization,
+					Err:      fmt.Errorf("field %q has %d values which exceeds the limit %d", name, len(form[name]), opt.MaxValuesPerKey),
+				},
+			)
+		}
+	}
+
+	form, formErr := transcodeForm(r, form)
+	if formErr != nil {
+		errs = append(errs, *formErr)
+	}
+	errs = mapForm(obj, form, nil, "form", opt, errs)
+	setStoredFiles(obj, stored, "form")
+
+	if opt.Strict {
+		fieldNames := formFieldNames(obj.Type(), "form", nil)
+		files := make(map[string][]*multipart.FileHeader, len(unknownFiles))
+		for _, name := range unknownFiles {
+			files[name] = nil
+		}
+		errs = checkUnknownKeys(fieldNames, form, files, errs)
+	}
+	return errs
+}
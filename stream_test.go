@@ -0,0 +1,190 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+func TestStreamMultipart(t *testing.T) {
+	type form struct {
+		Title string   `form:"title"`
+		Tags  []string `form:"tags"`
+	}
+
+	newRequest := func(t *testing.T) *http.Request {
+		return newMultipartRequest(t,
+			testPart{field: "title", content: "holiday"},
+			testPart{field: "photo", filename: "beach.jpg", content: "jpeg data"},
+			testPart{field: "tags", content: "sea"},
+			testPart{field: "tags", content: "sun"},
+			testPart{field: "thumbnail", filename: "beach.png", content: "png data"},
+		)
+	}
+
+	tests := []struct {
+		name     string
+		opts     func(got map[string]string) Options
+		wantForm form
+		wantGot  map[string]string
+		wantErrs Errors
+	}{
+		{
+			name: "sinks",
+			opts: func(got map[string]string) Options {
+				sink := func(part *FilePart) error {
+					data, err := io.ReadAll(part)
+					got[part.FileName] = string(data)
+					return err
+				}
+				return Options{
+					StreamMultipart: true,
+					FileSinks:       map[string]FileSink{"photo": sink, "thumbnail": sink},
+				}
+			},
+			wantForm: form{Title: "holiday", Tags: []string{"sea", "sun"}},
+			wantGot:  map[string]string{"beach.jpg": "jpeg data", "beach.png": "png data"},
+		},
+		{
+			name: "strict",
+			opts: func(got map[string]string) Options {
+				return Options{
+					StreamMultipart: true,
+					Strict:          true,
+					FileSinks: map[string]FileSink{
+						"photo": func(part *FilePart) error {
+							got[part.FileName] = ""
+							return nil
+						},
+					},
+				}
+			},
+			wantForm: form{Title: "holiday", Tags: []string{"sea", "sun"}},
+			wantGot:  map[string]string{"beach.jpg": ""},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`unknown field "thumbnail"`),
+				},
+			},
+		},
+		{
+			name: "sink error",
+			opts: func(got map[string]string) Options {
+				return Options{
+					StreamMultipart: true,
+					FileSinks: map[string]FileSink{
+						"photo": func(part *FilePart) error {
+							return errors.New("disk full")
+						},
+					},
+				}
+			},
+			wantForm: form{Title: "holiday"},
+			wantGot:  map[string]string{},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "photo": disk full`),
+				},
+			},
+		},
+		{
+			name: "values too large",
+			opts: func(got map[string]string) Options {
+				return Options{StreamMultipart: true, MaxMemory: 8}
+			},
+			wantForm: form{Title: "holiday"},
+			wantGot:  map[string]string{},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceMultipartForm,
+					Err:      errors.New("form values exceed the limit 8 bytes"),
+				},
+			},
+		},
+		{
+			name: "too many keys",
+			opts: func(got map[string]string) Options {
+				return Options{StreamMultipart: true, MaxKeys: 1}
+			},
+			wantGot: map[string]string{},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceMultipartForm,
+					Err:      errors.New("form has 2 keys which exceeds the limit 1"),
+				},
+			},
+		},
+		{
+			name: "too many values",
+			opts: func(got map[string]string) Options {
+				return Options{StreamMultipart: true, MaxValuesPerKey: 1}
+			},
+			wantGot: map[string]string{},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`field "tags" has 2 values which exceeds the limit 1`),
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := make(map[string]string)
+			var gotForm form
+			var gotErrs Errors
+			f := flamego.New()
+			f.Post("/", MultipartForm(form{}, test.opts(got)), func(form form, errs Errors) {
+				gotForm = form
+				gotErrs = errs
+			})
+
+			f.ServeHTTP(httptest.NewRecorder(), newRequest(t))
+
+			assert.Equal(t, test.wantForm, gotForm)
+			assert.Equal(t, test.wantGot, got)
+			assert.Equal(t, test.wantErrs, gotErrs)
+		})
+	}
+
+	t.Run("default parts limit", func(t *testing.T) {
+		parts := make([]testPart, defaultMaxStreamParts+1)
+		for i := range parts {
+			parts[i] = testPart{field: "tags", content: "sea"}
+		}
+
+		var gotErrs Errors
+		f := flamego.New()
+		f.Post("/", MultipartForm(form{}, Options{StreamMultipart: true}), func(errs Errors) {
+			gotErrs = errs
+		})
+
+		f.ServeHTTP(httptest.NewRecorder(), newMultipartRequest(t, parts...))
+
+		want := Errors{
+			{
+				Category: ErrorCategoryRequestTooLarge,
+				Source:   SourceMultipartForm,
+				Err:      errors.New("form has more than 1000 parts"),
+			},
+		}
+		assert.Equal(t, want, gotErrs)
+	})
+}
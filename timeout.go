@@ -0,0 +1,160 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// minReadRateGrace is the time allowed before Options.MinReadRate is enforced,
+// so that connection setup and the first bytes are not counted against the
+// throughput.
+const minReadRateGrace = time.Second
+
+// responseWriterContextKey is the request context key for the
+// http.ResponseWriter of the request, which is used to set read deadlines on
+// the underlying connection.
+type responseWriterContextKey struct{}
+
+// timeoutBody is the request body that fails reads once the deadline derived
+// from Options.ReadTimeout and Options.MinReadRate is exceeded, or the request
+// context is done. The deadline is set on the underlying connection when
+// supported, otherwise each read is waited in a separate goroutine that is
+// abandoned upon timeout.
+type timeoutBody struct {
+	src         io.ReadCloser
+	ctx         context.Context
+	setDeadline func(time.Time) error // Nil if not supported by the connection
+	timeout     time.Duration
+	minRate     int64
+
+	start    time.Time
+	read     int64         // The number of bytes read
+	err      error         // The error that stops reading
+	timedOut bool          // Whether the error is caused by timeout
+	pending  chan struct{} // Closed when the abandoned read returns, nil if none
+}
+
+func newTimeoutBody(r *http.Request, w http.ResponseWriter, opt Options) *timeoutBody {
+	return &timeoutBody{
+		src:         r.Body,
+		ctx:         r.Context(),
+		setDeadline: readDeadlineSetter(w),
+		timeout:     opt.ReadTimeout,
+		minRate:     opt.MinReadRate,
+		start:       time.Now(),
+	}
+}
+
+// deadline returns the deadline of the next read, along with the reason when
+// it is exceeded. It returns zero time if there is no deadline.
+func (b *timeoutBody) deadline() (time.Time, string) {
+	var deadline time.Time
+	var reason string
+	if b.timeout > 0 {
+		deadline = b.start.Add(b.timeout)
+		reason = fmt.Sprintf("not completed within %s", b.timeout)
+	}
+	if b.minRate > 0 {
+		d := b.start.Add(minReadRateGrace + time.Duration(float64(b.read)/float64(b.minRate)*float64(time.Second)))
+		if deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+			reason = fmt.Sprintf("slower than %d bytes per second", b.minRate)
+		}
+	}
+	return deadline, reason
+}
+
+func (b *timeoutBody) fail(reason string) error {
+	b.timedOut = true
+	b.err = fmt.Errorf("read request body: %s", reason)
+	return b.err
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if err := b.ctx.Err(); err != nil {
+		b.timedOut = err == context.DeadlineExceeded
+		b.err = fmt.Errorf("read request body: %v", err)
+		return 0, b.err
+	}
+
+	deadline, reason := b.deadline()
+	if b.setDeadline != nil {
+		err := b.setDeadline(deadline)
+		if err == nil {
+			n, err := b.src.Read(p)
+			b.read += int64(n)
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return n, b.fail(reason)
+			} else if err == io.EOF {
+				_ = b.setDeadline(time.Time{})
+			}
+			return n, err
+		}
+		// Fall back to wait in a goroutine when not supported.
+		b.setDeadline = nil
+	}
+
+	type result struct {
+		n   int
+		err error
+	}
+	buf := make([]byte, len(p))
+	done := make(chan result, 1)
+	returned := make(chan struct{})
+	go func() {
+		n, err := b.src.Read(buf)
+		done <- result{n: n, err: err}
+		close(returned)
+	}()
+
+	var expired <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case res := <-done:
+		n := copy(p, buf[:res.n])
+		b.read += int64(n)
+		return n, res.err
+	case <-expired:
+		b.pending = returned
+		return 0, b.fail(reason)
+	case <-b.ctx.Done():
+		b.pending = returned
+		b.timedOut = b.ctx.Err() == context.DeadlineExceeded
+		b.err = fmt.Errorf("read request body: %v", b.ctx.Err())
+		return 0, b.err
+	}
+}
+
+func (b *timeoutBody) Close() error {
+	if b.setDeadline != nil {
+		_ = b.setDeadline(time.Time{})
+	}
+
+	// Closing the body of net/http waits for the ongoing read, so the abandoned
+	// read is left to close it when it returns.
+	if b.pending != nil {
+		go func() {
+			<-b.pending
+			_ = b.src.Close()
+		}()
+		return nil
+	}
+	return b.src.Close()
+}
@@ -0,0 +1,197 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+// stalledBody returns a request body that sends the given content and then
+// stalls until the returned function is called.
+func stalledBody(content string) (io.Reader, func()) {
+	pr, pw := io.Pipe()
+	go func() { _, _ = pw.Write([]byte(content)) }()
+	return pr, func() { _ = pw.Close() }
+}
+
+// tricklingBody returns a request body that sends one byte per the interval.
+func tricklingBody(interval time.Duration) (io.Reader, func()) {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				_ = pw.Close()
+				return
+			case <-time.After(interval):
+				_, _ = pw.Write([]byte(" "))
+			}
+		}
+	}()
+	return pr, func() { close(done) }
+}
+
+func TestReadTimeout(t *testing.T) {
+	type form struct {
+		Username string `json:"username"`
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		body     func() (io.Reader, func())
+		wantErrs Errors
+	}{
+		{
+			name: "read timeout",
+			opts: Options{ReadTimeout: 50 * time.Millisecond},
+			body: func() (io.Reader, func()) { return stalledBody(`{"username": `) },
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryTimeout,
+					Source:   SourceJSON,
+					Err:      errors.New("read request body: not completed within 50ms"),
+				},
+			},
+		},
+		{
+			name: "minimum read rate",
+			opts: Options{MinReadRate: 100},
+			body: func() (io.Reader, func()) { return tricklingBody(100 * time.Millisecond) },
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryTimeout,
+					Source:   SourceJSON,
+					Err:      errors.New("read request body: slower than 100 bytes per second"),
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotErrs Errors
+			f := flamego.New()
+			f.Post("/", JSON(form{}, test.opts), func(errs Errors) {
+				gotErrs = errs
+			})
+
+			body, release := test.body()
+			defer release()
+
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, "/", body)
+			assert.Nil(t, err)
+
+			f.ServeHTTP(resp, req)
+			assert.Equal(t, test.wantErrs, gotErrs)
+		})
+	}
+
+	t.Run("context canceled", func(t *testing.T) {
+		body, release := stalledBody(`{"username": `)
+		defer release()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", body)
+		assert.Nil(t, err)
+
+		var got form
+		errs := DecodeJSON(req, &got, Options{ReadTimeout: time.Minute})
+		assert.Equal(t,
+			Errors{
+				{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceJSON,
+					Err:      errors.New("read request body: context canceled"),
+				},
+			},
+			errs,
+		)
+	})
+
+	t.Run("net/http", func(t *testing.T) {
+		gotErrs := make(chan Errors, 1)
+		server := httptest.NewServer(
+			Middleware(DecodeJSON, form{}, Options{ReadTimeout: 50 * time.Millisecond})(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					gotErrs <- ErrorsFromContext(r.Context())
+				}),
+			),
+		)
+		defer server.Close()
+
+		body, release := stalledBody(`{"username": `)
+		defer release()
+
+		req, err := http.NewRequest(http.MethodPost, server.URL, body)
+		assert.Nil(t, err)
+		go func() {
+			resp, err := http.DefaultClient.Do(req)
+			if err == nil {
+				_ = resp.Body.Close()
+			}
+		}()
+
+		select {
+		case errs := <-gotErrs:
+			assert.Len(t, errs, 1)
+			assert.Equal(t, ErrorCategoryTimeout, errs[0].Category)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the handler")
+		}
+	})
+
+	t.Run("flamego", func(t *testing.T) {
+		gotErrs := make(chan Errors, 1)
+		f := flamego.New()
+		f.Post("/", JSON(form{}, Options{ReadTimeout: 50 * time.Millisecond}), func(errs Errors) {
+			gotErrs <- errs
+		})
+		server := httptest.NewServer(f)
+		defer server.Close()
+
+		body, release := stalledBody(`{"username": `)
+		defer release()
+
+		req, err := http.NewRequest(http.MethodPost, server.URL, body)
+		assert.Nil(t, err)
+		go func() {
+			resp, err := http.DefaultClient.Do(req)
+			if err == nil {
+				_ = resp.Body.Close()
+			}
+		}()
+
+		select {
+		case errs := <-gotErrs:
+			assert.Equal(t,
+				Errors{
+					{
+						Category: ErrorCategoryTimeout,
+						Source:   SourceJSON,
+						Err:      errors.New("read request body: not completed within 50ms"),
+					},
+				},
+				errs,
+			)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the handler")
+		}
+	})
+}
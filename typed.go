@@ -0,0 +1,114 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"reflect"
+)
+
+// typedWrapper is implemented by typed wrappers, which let binders populate the
+// wrapped model type but map the wrapper type to the request context, so that
+// the same model type bound from different parts of the request are injected as
+// distinct types.
+type typedWrapper interface {
+	// wrappedType returns the wrapped model type.
+	wrappedType() reflect.Type
+	// wrap returns the wrapper of the given value of the wrapped model type.
+	wrap(v reflect.Value) interface{}
+}
+
+// FromQuery is a typed wrapper of a model bound from URL query parameters, e.g.
+// by binding.Query(binding.FromQuery[T]{}).
+type FromQuery[T any] struct {
+	Value T
+}
+
+func (FromQuery[T]) wrappedType() reflect.Type {
+	return typeOf[T]()
+}
+
+func (FromQuery[T]) wrap(v reflect.Value) interface{} {
+	return FromQuery[T]{Value: v.Interface().(T)}
+}
+
+// FromBody is a typed wrapper of a model bound from the request body, e.g. by
+// binding.JSON(binding.FromBody[T]{}).
+type FromBody[T any] struct {
+	Value T
+}
+
+func (FromBody[T]) wrappedType() reflect.Type {
+	return typeOf[T]()
+}
+
+func (FromBody[T]) wrap(v reflect.Value) interface{} {
+	return FromBody[T]{Value: v.Interface().(T)}
+}
+
+// FromHeader is a typed wrapper of a model bound from request headers, e.g. by
+// binding.Header(binding.FromHeader[T]{}).
+type FromHeader[T any] struct {
+	Value T
+}
+
+func (FromHeader[T]) wrappedType() reflect.Type {
+	return typeOf[T]()
+}
+
+func (FromHeader[T]) wrap(v reflect.Value) interface{} {
+	return FromHeader[T]{Value: v.Interface().(T)}
+}
+
+// FromParams is a typed wrapper of a model bound from bind parameters of the
+// route, e.g. by binding.Params(binding.FromParams[T]{}).
+type FromParams[T any] struct {
+	Value T
+}
+
+func (FromParams[T]) wrappedType() reflect.Type {
+	return typeOf[T]()
+}
+
+func (FromParams[T]) wrap(v reflect.Value) interface{} {
+	return FromParams[T]{Value: v.Interface().(T)}
+}
+
+// FromCookie is a typed wrapper of a model bound from request cookies, e.g. by
+// binding.Cookie(binding.FromCookie[T]{}).
+type FromCookie[T any] struct {
+	Value T
+}
+
+func (FromCookie[T]) wrappedType() reflect.Type {
+	return typeOf[T]()
+}
+
+func (FromCookie[T]) wrap(v reflect.Value) interface{} {
+	return FromCookie[T]{Value: v.Interface().(T)}
+}
+
+// typeOf returns the reflect.Type of the type parameter, which works for
+// interface types as well.
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// modelType returns the type that binders should populate for the model, which
+// is the wrapped model type if the model is a typed wrapper.
+func modelType(model interface{}) reflect.Type {
+	if w, ok := model.(typedWrapper); ok {
+		return w.wrappedType()
+	}
+	return reflect.TypeOf(model)
+}
+
+// mappedValue returns the value that binders should map to the request context
+// for the populated object (a pointer) of the model.
+func mappedValue(model interface{}, obj reflect.Value) interface{} {
+	if w, ok := model.(typedWrapper); ok {
+		return w.wrap(obj.Elem())
+	}
+	return obj.Elem().Interface()
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+func TestTypedWrappers(t *testing.T) {
+	t.Run("pointer model", func(t *testing.T) {
+		assert.PanicsWithValue(t,
+			"binding: pointer can not be accepted as binding model",
+			func() {
+				type filter struct {
+					Name string
+				}
+				Query(FromQuery[*filter]{})
+			},
+		)
+	})
+
+	type filter struct {
+		Name  string `query:"name" json:"name" header:"X-Name"`
+		Limit int    `query:"limit" json:"limit" header:"X-Limit" validate:"lte=100"`
+	}
+
+	var gotQuery FromQuery[filter]
+	var gotBody FromBody[filter]
+	var gotHeader FromHeader[filter]
+	var gotErrs Errors
+	f := flamego.New()
+	f.Post("/",
+		Query(FromQuery[filter]{}),
+		JSON(FromBody[filter]{}),
+		Header(FromHeader[filter]{}),
+		func(c flamego.Context, query FromQuery[filter], body FromBody[filter], header FromHeader[filter], errs Errors) {
+			gotQuery = query
+			gotBody = body
+			gotHeader = header
+			gotErrs = errs
+			assert.False(t, c.Value(typeOf[filter]()).IsValid())
+		},
+	)
+
+	resp := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodPost, "/?name=alice&limit=10", bytes.NewBufferString(`{"name": "bob", "limit": 20}`))
+	assert.Nil(t, err)
+
+	req.Header.Set("X-Name", "carol")
+	req.Header.Set("X-Limit", "30")
+	f.ServeHTTP(resp, req)
+
+	assert.Len(t, gotErrs, 0)
+	assert.Equal(t, filter{Name: "alice", Limit: 10}, gotQuery.Value)
+	assert.Equal(t, filter{Name: "bob", Limit: 20}, gotBody.Value)
+	assert.Equal(t, filter{Name: "carol", Limit: 30}, gotHeader.Value)
+}
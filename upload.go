@@ -0,0 +1,394 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fileTag is the restrictions of uploaded files for a form field, which is
+// given by the "file" struct tag in the form of
+// `file:"max=5MB,count=3,types=image/png|image/jpeg,ext=png|jpg"`.
+type fileTag struct {
+	maxSize  int64     // The maximum size in bytes of each file
+	maxCount int       // The maximum number of files
+	types    []string  // The allowed media types detected from the content
+	exts     []string  // The allowed filename extensions without the dot
+	image    *imageTag // The restrictions of images given by the "image" struct tag
+}
+
+// parseSize parses the size in bytes with an optional unit of "B", "KB", "MB"
+// or "GB", which are multiples of 1024.
+func parseSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	shift := 0
+	for _, unit := range []struct {
+		suffix string
+		shift  int
+	}{
+		{"KB", 10},
+		{"MB", 20},
+		{"GB", 30},
+		{"B", 0},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			shift = unit.shift
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return n << shift, nil
+}
+
+// parseFileTag parses the value of the "file" struct tag.
+func parseFileTag(tag string) (fileTag, error) {
+	var ft fileTag
+	for _, option := range strings.Split(tag, ",") {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(option, "=")
+		if !ok {
+			return fileTag{}, fmt.Errorf("option %q has no value", option)
+		}
+		switch strings.TrimSpace(key) {
+		case "max":
+			size, err := parseSize(value)
+			if err != nil {
+				return fileTag{}, err
+			}
+			ft.maxSize = size
+		case "count":
+			count, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || count < 0 {
+				return fileTag{}, fmt.Errorf("invalid count %q", value)
+			}
+			ft.maxCount = count
+		case "types":
+			for _, typ := range strings.Split(value, "|") {
+				if typ = strings.ToLower(strings.TrimSpace(typ)); typ != "" {
+					ft.types = append(ft.types, typ)
+				}
+			}
+		case "ext":
+			for _, ext := range strings.Split(value, "|") {
+				if ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), ".")); ext != "" {
+					ft.exts = append(ft.exts, ext)
+				}
+			}
+		default:
+			return fileTag{}, fmt.Errorf("unknown option %q", key)
+		}
+	}
+	return ft, nil
+}
+
+// fileFieldTags collects the "file" and "image" struct tags of all fields by
+// their form field names. It panics if any tag is malformed.
+func fileFieldTags(typ reflect.Type, tag string, tags map[string]fileTag) map[string]fileTag {
+	if tags == nil {
+		tags = make(map[string]fileTag)
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return tags
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		typeField := typ.Field(i)
+		if !typeField.IsExported() {
+			continue
+		}
+
+		fileValue, hasFile := typeField.Tag.Lookup("file")
+		imageValue, hasImage := typeField.Tag.Lookup("image")
+		if hasFile || hasImage {
+			ft, err := parseFileTag(fileValue)
+			if err != nil {
+				panic(fmt.Sprintf("binding: invalid file tag of field %q: %v", typeField.Name, err))
+			}
+			if hasImage {
+				ft.image, err = parseImageTag(imageValue)
+				if err != nil {
+					panic(fmt.Sprintf("binding: invalid image tag of field %q: %v", typeField.Name, err))
+				}
+			}
+			tags[formFieldName(typeField, tag)] = ft
+			continue
+		}
+
+		fieldType := typeField.Type
+		if fieldType.Kind() == reflect.Ptr && typeField.Anonymous {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct && fieldType != storedFileType {
+			tags = fileFieldTags(fieldType, tag, tags)
+		}
+	}
+	return tags
+}
+
+// uploadLimiter keeps track of parts and files of the multipart form against
+// the limits in the options and the "file" struct tags.
+type uploadLimiter struct {
+	opt    Options
+	fields map[string]fileTag
+
+	parts  int            // The number of parts
+	files  int            // The number of files
+	counts map[string]int // The number of files by form field names
+	size   int64          // The total size of files
+}
+
+func newUploadLimiter(obj reflect.Value, opt Options) *uploadLimiter {
+	return &uploadLimiter{
+		opt:    opt,
+		fields: fileFieldTags(obj.Type(), "form", nil),
+		counts: make(map[string]int),
+	}
+}
+
+// tooLarge returns an error for the exceeded limit.
+func tooLarge(format string, args ...interface{}) *Error {
+	return &Error{
+		Category: ErrorCategoryRequestTooLarge,
+		Err:      fmt.Errorf(format, args...),
+	}
+}
+
+// maxFileSize returns the maximum size of each file for the form field, the
+// "file" struct tag takes precedence over Options.MaxFileSize.
+func (l *uploadLimiter) maxFileSize(name string) int64 {
+	if limit, ok := l.fields[name]; ok && limit.maxSize > 0 {
+		return limit.maxSize
+	}
+	return l.opt.MaxFileSize
+}
+
+// addPart counts a part of the form.
+func (l *uploadLimiter) addPart() *Error {
+	l.parts++
+	if l.opt.MaxParts > 0 && l.parts > l.opt.MaxParts {
+		return tooLarge("form has more than %d parts", l.opt.MaxParts)
+	}
+	return nil
+}
+
+// addFile counts a file of the form field.
+func (l *uploadLimiter) addFile(name string) *Error {
+	l.files++
+	l.counts[name]++
+	if max := l.fields[name].maxCount; max > 0 && l.counts[name] > max {
+		return tooLarge("file field %q has more than %d files", name, max)
+	}
+	if l.opt.MaxFiles > 0 && l.files > l.opt.MaxFiles {
+		return tooLarge("file field %q: form has more than %d files", name, l.opt.MaxFiles)
+	}
+	return nil
+}
+
+// checkFileSize checks the size of the file of the form field, the total size
+// of files is increased by delta.
+func (l *uploadLimiter) checkFileSize(name, filename string, size, delta int64) *Error {
+	l.size += delta
+	if max := l.maxFileSize(name); max > 0 && size > max {
+		return tooLarge("file field %q: file %q exceeds the limit %d bytes", name, filename, max)
+	}
+	if l.opt.MaxUploadSize > 0 && l.size > l.opt.MaxUploadSize {
+		return tooLarge("file field %q: files exceed the total limit %d bytes", name, l.opt.MaxUploadSize)
+	}
+	return nil
+}
+
+// checkForm checks the parsed multipart form against the limits, and reports
+// errors of every form field that exceeds them. The total limits are only
+// reported for the form field that first exceeds them.
+func (l *uploadLimiter) checkForm(form *multipart.Form) Errors {
+	for _, values := range form.Value {
+		l.parts += len(values)
+	}
+	for _, fhs := range form.File {
+		l.parts += len(fhs)
+	}
+	if l.opt.MaxParts > 0 && l.parts > l.opt.MaxParts {
+		return Errors{*tooLarge("form has more than %d parts", l.opt.MaxParts)}
+	}
+
+	names := make([]string, 0, len(form.File))
+	for name := range form.File {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs Errors
+	for _, name := range names {
+		fhs := form.File[name]
+		if max := l.fields[name].maxCount; max > 0 && len(fhs) > max {
+			errs = append(errs, *tooLarge("file field %q has more than %d files", name, max))
+		}
+		for _, fh := range fhs {
+			l.files++
+			if l.opt.MaxFiles > 0 && l.files == l.opt.MaxFiles+1 {
+				errs = append(errs, *tooLarge("file field %q: form has more than %d files", name, l.opt.MaxFiles))
+			}
+			if max := l.maxFileSize(name); max > 0 && fh.Size > max {
+				errs = append(errs, *tooLarge("file field %q: file %q exceeds the limit %d bytes", name, fh.Filename, max))
+			}
+			exceeded := l.opt.MaxUploadSize > 0 && l.size > l.opt.MaxUploadSize
+			l.size += fh.Size
+			if !exceeded && l.opt.MaxUploadSize > 0 && l.size > l.opt.MaxUploadSize {
+				errs = append(errs, *tooLarge("file field %q: files exceed the total limit %d bytes", name, l.opt.MaxUploadSize))
+			}
+		}
+	}
+	return errs
+}
+
+// limited returns true if any upload limit is set.
+func (l *uploadLimiter) limited() bool {
+	if l.opt.MaxFileSize > 0 || l.opt.MaxUploadSize > 0 || l.opt.MaxFiles > 0 || l.opt.MaxParts > 0 {
+		return true
+	}
+	for _, ft := range l.fields {
+		if ft.maxSize > 0 || ft.maxCount > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// readForm parses the request body as multipart form like
+// multipart.Reader.ReadForm, and stores the result to r.MultipartForm. Parts are
+// walked against the limits ahead of ReadForm, so that reading stops at the
+// first part that exceeds them, in which case the error of the exceeded limit is
+// returned.
+func (l *uploadLimiter) readForm(r *http.Request, maxMemory int64) (*Error, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	w := multipart.NewWriter(pw)
+	var limitErr *Error
+	var readErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		limitErr, readErr = l.copyParts(w, mr)
+		if limitErr != nil {
+			_ = pw.CloseWithError(limitErr.Err)
+		} else {
+			_ = pw.CloseWithError(readErr)
+		}
+	}()
+
+	form, err := multipart.NewReader(pr, w.Boundary()).ReadForm(maxMemory)
+	// Unblock the copying if ReadForm stops early.
+	_ = pr.Close()
+	<-done
+
+	r.MultipartForm = form
+	if limitErr != nil {
+		return limitErr, nil
+	} else if readErr != nil && readErr != io.ErrClosedPipe {
+		return nil, readErr
+	}
+	return nil, err
+}
+
+// copyParts copies parts of the multipart form from the reader to the writer
+// until any part exceeds the limits. Errors of writing are io.ErrClosedPipe
+// once the reading side of the writer is closed.
+func (l *uploadLimiter) copyParts(w *multipart.Writer, mr *multipart.Reader) (*Error, error) {
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, w.Close()
+		} else if err != nil {
+			return nil, err
+		}
+
+		// Parts are not closed when giving up reading, which would drain them.
+		if limitErr := l.addPart(); limitErr != nil {
+			return limitErr, nil
+		}
+
+		name := part.FormName()
+		if name == "" {
+			_ = part.Close()
+			continue
+		}
+
+		var content io.Reader = part
+		var limited *limitedFileReader
+		if part.FileName() != "" {
+			if limitErr := l.addFile(name); limitErr != nil {
+				return limitErr, nil
+			}
+			limited = &limitedFileReader{
+				r:        part,
+				limiter:  l,
+				name:     name,
+				filename: part.FileName(),
+			}
+			content = limited
+		}
+
+		pw, err := w.CreatePart(part.Header)
+		if err == nil {
+			_, err = io.Copy(pw, content)
+		}
+		if limited != nil && limited.err != nil {
+			return limited.err, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// limitedFileReader is the content of a file part being streamed, which fails
+// reads once the file exceeds the limits.
+type limitedFileReader struct {
+	r        io.Reader
+	limiter  *uploadLimiter
+	name     string
+	filename string
+
+	n   int64  // The number of bytes read
+	err *Error // The exceeded limit
+}
+
+func (r *limitedFileReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err.Err
+	}
+
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.n += int64(n)
+		r.err = r.limiter.checkFileSize(r.name, r.filename, r.n, int64(n))
+		if r.err != nil {
+			return 0, r.err.Err
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,371 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+// testPart is a part of the multipart form in tests, which is a file part if
+// the filename is not empty.
+type testPart struct {
+	field    string
+	filename string // The filename is sent as is without escaping
+	content  string
+	header   map[string]string // Additional headers of the part
+}
+
+// newMultipartRequest returns a new POST request with the multipart form that
+// consists of the parts in order.
+func newMultipartRequest(t *testing.T, parts ...testPart) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, p := range parts {
+		h := make(textproto.MIMEHeader)
+		if p.filename != "" {
+			h.Set("Content-Disposition", `form-data; name="`+p.field+`"; filename="`+p.filename+`"`)
+			h.Set("Content-Type", "application/octet-stream")
+		} else {
+			h.Set("Content-Disposition", `form-data; name="`+p.field+`"`)
+		}
+		for k, v := range p.header {
+			h.Set(k, v)
+		}
+
+		pw, err := w.CreatePart(h)
+		assert.Nil(t, err)
+		_, err = pw.Write([]byte(p.content))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+
+	req, err := http.NewRequest(http.MethodPost, "/", &body)
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func TestParseFileTag(t *testing.T) {
+	tests := []struct {
+		tag     string
+		want    fileTag
+		wantErr string
+	}{
+		{tag: "", want: fileTag{}},
+		{tag: "max=512", want: fileTag{maxSize: 512}},
+		{tag: "max=5MB,count=3", want: fileTag{maxSize: 5 << 20, maxCount: 3}},
+		{tag: "max = 2kb, count = 1", want: fileTag{maxSize: 2 << 10, maxCount: 1}},
+		{tag: "max=1GB", want: fileTag{maxSize: 1 << 30}},
+		{
+			tag:  "types=image/PNG|image/*,ext=.PNG|jpg",
+			want: fileTag{types: []string{"image/png", "image/*"}, exts: []string{"png", "jpg"}},
+		},
+		{tag: "max=5TB", wantErr: `invalid size "5TB"`},
+		{tag: "count=-1", wantErr: `invalid count "-1"`},
+		{tag: "count", wantErr: `option "count" has no value`},
+		{tag: "min=1", wantErr: `unknown option "min"`},
+	}
+	for _, test := range tests {
+		t.Run(test.tag, func(t *testing.T) {
+			got, err := parseFileTag(test.tag)
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+
+	t.Run("invalid tag", func(t *testing.T) {
+		type form struct {
+			Avatar *multipart.FileHeader `form:"avatar" file:"max=big"`
+		}
+		assert.PanicsWithValue(t,
+			`binding: invalid file tag of field "Avatar": invalid size "big"`,
+			func() { _ = fileFieldTags(reflect.TypeOf(form{}), "form", nil) },
+		)
+	})
+}
+
+func TestUploadLimits(t *testing.T) {
+	type form struct {
+		Title    string                  `form:"title"`
+		Avatar   *multipart.FileHeader   `form:"avatar" file:"max=8"`
+		Photos   []*multipart.FileHeader `form:"photos" file:"count=2"`
+		Document *multipart.FileHeader   `form:"document"`
+	}
+
+	type file struct {
+		field, name, content string
+	}
+	newRequest := func(t *testing.T, files []file) *http.Request {
+		parts := []testPart{{field: "title", content: "holiday"}}
+		for _, f := range files {
+			parts = append(parts, testPart{field: f.field, filename: f.name, content: f.content})
+		}
+		return newMultipartRequest(t, parts...)
+	}
+
+	tests := []struct {
+		name  string
+		opts  Options
+		files []file
+		// The errors of reading the multipart form, which stops at the first
+		// exceeded limit, and of the multipart form parsed before binding.
+		wantErrs       Errors
+		wantParsedErrs Errors
+	}{
+		{
+			name: "within limits",
+			opts: Options{MaxFileSize: 16, MaxUploadSize: 32, MaxFiles: 3, MaxParts: 4},
+			files: []file{
+				{"avatar", "me.png", "avatar"},
+				{"photos", "1.jpg", "photo 1"},
+				{"photos", "2.jpg", "photo 2"},
+			},
+		},
+		{
+			name: "file size by tag",
+			files: []file{
+				{"avatar", "me.png", "too large avatar"},
+				{"document", "cv.pdf", "too large document"},
+			},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryRequestTooLarge,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "avatar": file "me.png" exceeds the limit 8 bytes`),
+				},
+			},
+		},
+		{
+			name: "file size",
+			opts: Options{MaxFileSize: 10},
+			files: []file{
+				{"avatar", "me.png", "avatar"},
+				{"document", "cv.pdf", "too large document"},
+			},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryRequestTooLarge,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "document": file "cv.pdf" exceeds the limit 10 bytes`),
+				},
+			},
+		},
+		{
+			name: "upload size",
+			opts: Options{MaxUploadSize: 10},
+			files: []file{
+				{"photos", "1.jpg", "photo 1"},
+				{"photos", "2.jpg", "photo 2"},
+			},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryRequestTooLarge,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "photos": files exceed the total limit 10 bytes`),
+				},
+			},
+		},
+		{
+			name: "file count by tag",
+			files: []file{
+				{"photos", "1.jpg", "photo 1"},
+				{"photos", "2.jpg", "photo 2"},
+				{"photos", "3.jpg", "photo 3"},
+			},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryRequestTooLarge,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "photos" has more than 2 files`),
+				},
+			},
+		},
+		{
+			name: "file count",
+			opts: Options{MaxFiles: 1},
+			files: []file{
+				{"document", "cv.pdf", "document"},
+				{"avatar", "me.png", "avatar"},
+			},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryRequestTooLarge,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "avatar": form has more than 1 files`),
+				},
+			},
+			wantParsedErrs: Errors{
+				{
+					Category: ErrorCategoryRequestTooLarge,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "document": form has more than 1 files`),
+				},
+			},
+		},
+		{
+			name: "part count",
+			opts: Options{MaxParts: 2},
+			files: []file{
+				{"avatar", "me.png", "avatar"},
+				{"document", "cv.pdf", "document"},
+			},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryRequestTooLarge,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`form has more than 2 parts`),
+				},
+			},
+		},
+		{
+			name: "multiple fields",
+			opts: Options{MaxFileSize: 10},
+			files: []file{
+				{"avatar", "me.png", "too large avatar"},
+				{"document", "cv.pdf", "too large document"},
+			},
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryRequestTooLarge,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "avatar": file "me.png" exceeds the limit 8 bytes`),
+				},
+			},
+			wantParsedErrs: Errors{
+				{
+					Category: ErrorCategoryRequestTooLarge,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "avatar": file "me.png" exceeds the limit 8 bytes`),
+				},
+				{
+					Category: ErrorCategoryRequestTooLarge,
+					Source:   SourceMultipartForm,
+					Err:      errors.New(`file field "document": file "cv.pdf" exceeds the limit 10 bytes`),
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Run("staged", func(t *testing.T) {
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}, test.opts), func(errs Errors) {
+					gotErrs = errs
+				})
+
+				resp := httptest.NewRecorder()
+				req := newRequest(t, test.files)
+
+				f.ServeHTTP(resp, req)
+				assert.Equal(t, test.wantErrs, gotErrs)
+			})
+
+			t.Run("parsed", func(t *testing.T) {
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}, test.opts), func(errs Errors) {
+					gotErrs = errs
+				})
+
+				resp := httptest.NewRecorder()
+				req := newRequest(t, test.files)
+				assert.Nil(t, req.ParseMultipartForm(1<<20))
+				defer func() { _ = req.MultipartForm.RemoveAll() }()
+
+				f.ServeHTTP(resp, req)
+
+				wantErrs := test.wantErrs
+				if test.wantParsedErrs != nil {
+					wantErrs = test.wantParsedErrs
+				}
+				assert.Equal(t, wantErrs, gotErrs)
+			})
+
+			t.Run("streamed", func(t *testing.T) {
+				got := make(map[string]string)
+				sink := func(part *FilePart) error {
+					data, err := io.ReadAll(part)
+					if err != nil {
+						return err
+					}
+					got[part.FieldName] += string(data)
+					return nil
+				}
+				opts := test.opts
+				opts.StreamMultipart = true
+				opts.FileSinks = map[string]FileSink{"avatar": sink, "photos": sink, "document": sink}
+
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}, opts), func(errs Errors) {
+					gotErrs = errs
+				})
+
+				resp := httptest.NewRecorder()
+				req := newRequest(t, test.files)
+
+				f.ServeHTTP(resp, req)
+
+				assert.Equal(t, test.wantErrs, gotErrs)
+				if test.wantErrs == nil {
+					for _, f := range test.files {
+						assert.True(t, strings.Contains(got[f.field], f.content))
+					}
+				}
+			})
+		})
+	}
+
+	t.Run("stop reading early", func(t *testing.T) {
+		for name, opts := range map[string]Options{
+			"staged":   {MaxFileSize: 10},
+			"streamed": {MaxFileSize: 10, StreamMultipart: true},
+		} {
+			t.Run(name, func(t *testing.T) {
+				req := newRequest(t, []file{
+					{"document", "cv.pdf", strings.Repeat("a", 1<<20)},
+					{"avatar", "me.png", "avatar"},
+				})
+				body := &countingReader{r: req.Body}
+				req.Body = io.NopCloser(body)
+
+				var gotErrs Errors
+				f := flamego.New()
+				f.Post("/", MultipartForm(form{}, opts), func(errs Errors) {
+					gotErrs = errs
+				})
+
+				f.ServeHTTP(httptest.NewRecorder(), req)
+				want := Errors{
+					{
+						Category: ErrorCategoryRequestTooLarge,
+						Source:   SourceMultipartForm,
+						Err:      errors.New(`file field "document": file "cv.pdf" exceeds the limit 10 bytes`),
+					},
+				}
+				assert.Equal(t, want, gotErrs)
+				assert.Less(t, body.n, req.ContentLength/2)
+			})
+		}
+	})
+}
@@ -0,0 +1,138 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/flamego/flamego"
+)
+
+// Version is a version of the model accepted by binding.Versioned.
+type Version struct {
+	// Model is the model of the version, it can be omitted when the version uses
+	// the latest model.
+	Model interface{}
+	// Upgrade converts an instance of the model of the version to the latest
+	// model, and must be in the form of `func(Old) Latest` or
+	// `func(Old) (Latest, error)`. It is required when the model of the version is
+	// not the latest model.
+	Upgrade interface{}
+}
+
+// versionDecoder decodes the payload of a version and upgrades it to the latest
+// model.
+type versionDecoder struct {
+	typ     reflect.Type
+	upgrade reflect.Value // The zero Value if no upgrade is needed
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// newVersionDecoder validates the version against the latest model type.
+func newVersionDecoder(mediaType string, version Version, latest reflect.Type) versionDecoder {
+	if version.Model == nil {
+		version.Model = reflect.Zero(latest).Interface()
+	}
+	ensureNotPointer(version.Model)
+	typ := modelType(version.Model)
+	if version.Upgrade == nil {
+		if typ != latest {
+			panic(fmt.Sprintf("binding: upgrade function of %q must be given for model %s", mediaType, typ))
+		}
+		return versionDecoder{typ: typ}
+	}
+
+	upgrade := reflect.ValueOf(version.Upgrade)
+	ft := upgrade.Type()
+	if ft.Kind() != reflect.Func ||
+		ft.NumIn() != 1 || ft.In(0) != typ ||
+		ft.NumOut() < 1 || ft.NumOut() > 2 || ft.Out(0) != latest ||
+		(ft.NumOut() == 2 && ft.Out(1) != errorType) {
+		panic(fmt.Sprintf("binding: upgrade function of %q must be in the form of func(%s) %s or func(%s) (%s, error)", mediaType, typ, latest, typ, latest))
+	}
+	return versionDecoder{typ: typ, upgrade: upgrade}
+}
+
+// decode decodes the request body into a new instance of the model of the
+// version, and upgrades it into the object of the latest model.
+func (d versionDecoder) decode(
+	r *http.Request,
+	obj reflect.Value,
+	opt Options,
+	decodeBody func(r *http.Request, obj reflect.Value, opt Options) Errors,
+) Errors {
+	if !d.upgrade.IsValid() {
+		return decodeBody(r, obj, opt)
+	}
+
+	old := reflect.New(d.typ)
+	errs := decodeBody(r, old, opt)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	out := d.upgrade.Call([]reflect.Value{old.Elem()})
+	if len(out) == 2 && !out[1].IsNil() {
+		return Errors{
+			{
+				Category: ErrorCategoryDeserialization,
+				Err:      fmt.Errorf("upgrade: %v", out[1].Interface()),
+			},
+		}
+	}
+	obj.Elem().Set(out[0])
+	return nil
+}
+
+// Versioned returns a middleware handler that injects a new instance of the
+// latest model with populated fields and binding.Errors for any
+// deserialization, binding, or validation errors into the request context. The
+// versions are keyed by vendor media types like
+// "application/vnd.acme.order.v2+json", the version is selected by the
+// Content-Type of the request, and its payload is deserialized as YAML for the
+// "+yaml" suffix or as JSON otherwise. The payload of older versions is
+// upgraded to the latest model before validation. Requests without
+// Content-Type are deserialized as JSON into the latest model, and other media
+// types are rejected with ErrorCategoryUnsupportedMediaType.
+func Versioned(model interface{}, versions map[string]Version, opts ...Options) flamego.Handler {
+	ensureNotPointer(model)
+
+	latest := modelType(model)
+	decoders := make(map[string]versionDecoder, len(versions))
+	mediaTypes := make([]string, 0, len(versions))
+	for mediaType, version := range versions {
+		mediaType = strings.ToLower(mediaType)
+		decoders[mediaType] = newVersionDecoder(mediaType, version, latest)
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+
+	return bind("Versioned", model, "", mediaTypes, opts, func(c flamego.Context, obj reflect.Value, opt Options) Errors {
+		r := c.Request().Request
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		d, ok := decoders[mediaType]
+		if !ok {
+			// Requests without Content-Type, or with media types additionally
+			// allowed by Options.MediaTypes, are decoded into the latest model.
+			d = versionDecoder{typ: latest}
+		}
+
+		source, decodeBody := SourceJSON, decodeJSON
+		if strings.HasSuffix(mediaType, "+yaml") {
+			source, decodeBody = SourceYAML, decodeYAML
+		}
+		errs := d.decode(r, obj, opt, decodeBody)
+		for i := range errs {
+			errs[i].Source = source
+		}
+		return errs
+	})
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 Flamego. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flamego/flamego"
+)
+
+func TestVersioned(t *testing.T) {
+	type orderV1 struct {
+		Item string `json:"item" yaml:"item"`
+	}
+	type orderV2 struct {
+		Items    []string `json:"items"`
+		Quantity int      `json:"quantity"`
+	}
+
+	handler := Versioned(orderV2{}, map[string]Version{
+		"application/vnd.acme.order.v1+json": {
+			Model: orderV1{},
+			Upgrade: func(v orderV1) orderV2 {
+				return orderV2{Items: []string{v.Item}, Quantity: 1}
+			},
+		},
+		"application/vnd.acme.order.v1+yaml": {
+			Model: orderV1{},
+			Upgrade: func(v orderV1) (orderV2, error) {
+				if v.Item == "" {
+					return orderV2{}, errors.New("item is required")
+				}
+				return orderV2{Items: []string{v.Item}, Quantity: 1}, nil
+			},
+		},
+		"application/vnd.acme.order.v2+json": {},
+	})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantOrder   orderV2
+		wantErrs    Errors
+	}{
+		{
+			name:        "latest",
+			contentType: "application/vnd.acme.order.v2+json",
+			body:        `{"items": ["apple", "banana"], "quantity": 2}`,
+			wantOrder:   orderV2{Items: []string{"apple", "banana"}, Quantity: 2},
+		},
+		{
+			name:        "upgraded",
+			contentType: "application/vnd.acme.order.v1+json; charset=utf-8",
+			body:        `{"item": "apple"}`,
+			wantOrder:   orderV2{Items: []string{"apple"}, Quantity: 1},
+		},
+		{
+			name:        "upgraded from yaml",
+			contentType: "application/vnd.acme.order.v1+yaml",
+			body:        "item: apple",
+			wantOrder:   orderV2{Items: []string{"apple"}, Quantity: 1},
+		},
+		{
+			name:      "no content type",
+			body:      `{"items": ["apple"]}`,
+			wantOrder: orderV2{Items: []string{"apple"}},
+		},
+		{
+			name:        "upgrade error",
+			contentType: "application/vnd.acme.order.v1+yaml",
+			body:        "item: ''",
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryDeserialization,
+					Source:   SourceYAML,
+					Err:      errors.New("upgrade: item is required"),
+				},
+			},
+		},
+		{
+			name:        "unknown version",
+			contentType: "application/vnd.acme.order.v3+json",
+			body:        `{"items": ["apple"]}`,
+			wantErrs: Errors{
+				{
+					Category: ErrorCategoryUnsupportedMediaType,
+					Err:      errors.New(`unsupported media type "application/vnd.acme.order.v3+json"`),
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotOrder orderV2
+			var gotErrs Errors
+			f := flamego.New()
+			f.Post("/", handler, func(order orderV2, errs Errors) {
+				gotOrder = order
+				gotErrs = errs
+			})
+
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(test.body))
+			assert.Nil(t, err)
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+
+			f.ServeHTTP(resp, req)
+
+			assert.Equal(t, test.wantOrder, gotOrder)
+			assert.Equal(t, test.wantErrs, gotErrs)
+		})
+	}
+
+	t.Run("header-bound fields", func(t *testing.T) {
+		type orderV1 struct {
+			Item string `json:"item"`
+		}
+		type orderV2 struct {
+			Items []string `json:"items"`
+			Token string   `header:"X-Token"`
+		}
+
+		var gotOrder orderV2
+		var gotErrs Errors
+		f := flamego.New()
+		f.Post("/",
+			Versioned(orderV2{}, map[string]Version{
+				"application/vnd.acme.order.v1+json": {
+					Model: orderV1{},
+					Upgrade: func(v orderV1) orderV2 {
+						return orderV2{Items: []string{v.Item}, Token: "forged"}
+					},
+				},
+			}, Options{MaxKeys: 2}),
+			func(order orderV2, errs Errors) {
+				gotOrder = order
+				gotErrs = errs
+			},
+		)
+
+		req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"item": "apple"}`))
+		assert.Nil(t, err)
+		req.Header.Set("Content-Type", "application/vnd.acme.order.v1+json")
+		req.Header.Set("X-Token", "secret")
+		req.Header.Set("X-Request-Id", "1")
+		f.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Nil(t, gotErrs)
+		assert.Equal(t, orderV2{Items: []string{"apple"}, Token: "secret"}, gotOrder)
+	})
+
+	t.Run("invalid upgrade", func(t *testing.T) {
+		defer func() {
+			r := recover()
+			assert.True(t, strings.HasPrefix(r.(string), `binding: upgrade function of "application/vnd.acme.order.v1+json" must be in the form of`), r)
+		}()
+		Versioned(orderV2{}, map[string]Version{
+			"application/vnd.acme.order.v1+json": {
+				Model:   orderV1{},
+				Upgrade: func(v orderV2) orderV2 { return v },
+			},
+		})
+	})
+
+	t.Run("missing upgrade", func(t *testing.T) {
+		defer func() {
+			assert.Equal(t, `binding: upgrade function of "application/vnd.acme.order.v1+json" must be given for model binding.orderV1`, recover())
+		}()
+		Versioned(orderV2{}, map[string]Version{
+			"application/vnd.acme.order.v1+json": {Model: orderV1{}},
+		})
+	})
+}